@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"log"
 	"net/http"
 	"os"
 	"time"
@@ -51,6 +52,12 @@ func (h *AuthHandler) BootstrapAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check the password against the policy
+	if err := helpers.ValidatePassword(req.Password, req.Email); err != nil {
+		http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -134,6 +141,12 @@ func (h *AuthHandler) AddAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// ===== Check password policy =====
+	if err := helpers.ValidatePassword(req.Password, req.Email); err != nil {
+		http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
 	// ===== Hash password =====
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -201,6 +214,12 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check the password against the policy
+	if err := helpers.ValidatePassword(input.Password, input.Email); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Hash the password for security before storing
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -277,6 +296,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Device   string `json:"device"` // optional name shown in the session list
 	}
 
 	// Decode JSON request body
@@ -308,22 +328,16 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create JWT token for authenticated user
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID.Hex(),
-		"role":    user.Role,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
-
-	// Sign the token with secret from environment variable
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	// Start a session with a short lived access token and a refresh token,
+	// or only a restricted token while the password has to be changed
+	tokens, err := h.startSession(r, user, input.Device)
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
-	// Send token back to the client
-	json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
+	// Send tokens back to the client
+	json.NewEncoder(w).Encode(tokens)
 }
 
 // ApproveUser function handles admin approval of pending registrations
@@ -379,7 +393,7 @@ func (h *AuthHandler) ApproveUser(w http.ResponseWriter, r *http.Request) {
 
 	// Insert approved user into the "users" collection
 	users := h.DB.Collection("users")
-	_, err = users.InsertOne(context.Background(), models.User{
+	inserted, err := users.InsertOne(context.Background(), models.User{
 		Email:             pendingUser.Email,
 		Password:          pendingUser.Password,
 		Name:              pendingUser.Name,
@@ -391,7 +405,7 @@ func (h *AuthHandler) ApproveUser(w http.ResponseWriter, r *http.Request) {
 		Verified:          true,
 		BooksRead:         0,
 		RankScore:         0,
-		ClassTag:          "beginner",
+		ClassTag:          helpers.DefaultClassTag(),
 		CreatedAt:         time.Now(),
 		MustChangePassword: false, 
 	})
@@ -401,10 +415,10 @@ func (h *AuthHandler) ApproveUser(w http.ResponseWriter, r *http.Request) {
 		}
 		
 // Assign "Beginner" badge upon approval
-	BadgeCollection := h.DB.Collection("badges")
+	BadgeCollection := h.DB.Collection("Badges")
 	_, err = BadgeCollection.InsertOne(context.Background(), models.Badge{
-		UserID:      pendingUser.ID,
-		Name:        "Beginner",
+		UserID:      inserted.InsertedID.(primitive.ObjectID),
+		Name:        helpers.DefaultClassTag(),
 		Description: "joined the community!",
 		Type:        "class-tag",
 		CreatedAt:   time.Now(),
@@ -422,11 +436,58 @@ if err != nil {
 		return
 	}
 
+	// Let the student know they can log in now
+	if err := helpers.QueueEmail(h.DB, pendingUser.Email, helpers.MailRegistrationApproved, map[string]any{
+		"Name":     pendingUser.Name,
+		"ReaderID": pendingUser.ReaderID,
+	}); err != nil {
+		log.Printf("failed to queue approval email for %s: %v", pendingUser.Email, err)
+	}
+
 	// Send success response to client
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "User approved"})
 }
 
+// RejectUser removes a pending registration and emails the student why
+func (h *AuthHandler) RejectUser(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		Email  string `json:"email"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	pending := h.DB.Collection("pending_registrations")
+	var pendingUser models.PendingRegistration
+	if err := pending.FindOneAndDelete(context.Background(), bson.M{"email": input.Email}).Decode(&pendingUser); err != nil {
+		http.Error(w, "Pending user not found", http.StatusNotFound)
+		return
+	}
+
+	if err := helpers.QueueEmail(h.DB, pendingUser.Email, helpers.MailRegistrationRejected, map[string]any{
+		"Name":   pendingUser.Name,
+		"Reason": input.Reason,
+	}); err != nil {
+		log.Printf("failed to queue rejection email for %s: %v", pendingUser.Email, err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Registration rejected"})
+}
+
 
 func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	// ===== JWT Authentication =====
@@ -485,6 +546,16 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// ===== Check password policy =====
+	if req.NewPassword == req.CurrentPassword {
+		http.Error(w, `{"error": "New password must be different from the current one"}`, http.StatusBadRequest)
+		return
+	}
+	if err := helpers.ValidatePassword(req.NewPassword, user.Email); err != nil {
+		http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
 	// ===== Hash new password =====
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
@@ -496,15 +567,32 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	_, err = usersCol.UpdateOne(
 		context.Background(),
 		bson.M{"_id": userID},
-		bson.M{"$set": bson.M{"password": string(hashedPassword)}},
+		bson.M{"$set": bson.M{"password": string(hashedPassword), "must_change_password": false}},
 	)
 	if err != nil {
 		http.Error(w, `{"error": "Failed to update password"}`, http.StatusInternalServerError)
 		return
 	}
 
+	// ===== End the other sessions, a restricted token gets a real session =====
+	user.MustChangePassword = false
+	sessionID := sessionFromToken(r)
+	if err := helpers.RevokeOtherSessions(h.DB, userID, sessionID); err != nil {
+		http.Error(w, `{"error": "Failed to end other sessions"}`, http.StatusInternalServerError)
+		return
+	}
+	response := map[string]any{}
+	if sessionID.IsZero() {
+		response, err = h.startSession(r, user, "")
+	} else {
+		response["token"], err = issueAccessToken(user, sessionID)
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
+		return
+	}
+	response["message"] = "Password changed successfully"
+
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Password changed successfully",
-	})
+	json.NewEncoder(w).Encode(response)
 }
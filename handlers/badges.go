@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GET /badges/catalog lists the badges that can be earned (admins can add ?all=true to see retired ones)
+func (h *SocialHandler) BadgeCatalog(w http.ResponseWriter, r *http.Request) {
+	activeOnly := true
+	if r.URL.Query().Get("all") == "true" {
+		if _, role, ok := requesterFromToken(r); ok && role == "admin" {
+			activeOnly = false
+		}
+	}
+
+	catalog, err := helpers.BadgeCatalog(h.DB, activeOnly)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch badge catalog"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"count":    len(catalog),
+		"badges":   catalog,
+		"counters": helpers.BadgeCounters,
+	})
+}
+
+// POST /badges/catalog lets the admin add a badge or change one (matched by name)
+func (h *SocialHandler) SaveBadgeDefinition(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		Name        string                  `json:"name"`
+		Description string                  `json:"description"`
+		Icon        string                  `json:"icon"`
+		Score       int                     `json:"score"`
+		Rule        []models.BadgeCondition `json:"rule"`
+		Active      *bool                   `json:"active"` // defaults to true
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+
+	def := models.BadgeDefinition{
+		Name:        input.Name,
+		Description: input.Description,
+		Icon:        input.Icon,
+		Score:       input.Score,
+		Rule:        input.Rule,
+		Active:      input.Active == nil || *input.Active,
+		UpdatedAt:   time.Now(),
+	}
+	if err := helpers.ValidateBadgeDefinition(def); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// make sure the defaults are seeded before the first admin change
+	if _, err := helpers.BadgeCatalog(h.DB, false); err != nil {
+		http.Error(w, `{"error": "Failed to load badge catalog"}`, http.StatusInternalServerError)
+		return
+	}
+
+	res, err := h.DB.Collection("BadgeDefinitions").UpdateOne(context.Background(),
+		bson.M{"name": def.Name},
+		bson.M{"$set": bson.M{
+			"description": def.Description,
+			"icon":        def.Icon,
+			"score":       def.Score,
+			"rule":        def.Rule,
+			"active":      def.Active,
+			"updated_at":  def.UpdatedAt,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to save badge"}`, http.StatusInternalServerError)
+		return
+	}
+
+	message := "Badge updated"
+	status := http.StatusOK
+	if res.UpsertedCount > 0 {
+		message = "Badge created"
+		status = http.StatusCreated
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": message,
+		"badge":   def,
+	})
+}
+
+// DELETE /badges/catalog?name=<name> retires a badge; readers who earned it keep it
+func (h *SocialHandler) RetireBadgeDefinition(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	res, err := h.DB.Collection("BadgeDefinitions").UpdateOne(context.Background(),
+		bson.M{"name": r.URL.Query().Get("name")},
+		bson.M{"$set": bson.M{"active": false, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to retire badge"}`, http.StatusInternalServerError)
+		return
+	}
+	if res.MatchedCount == 0 {
+		http.Error(w, `{"error": "Badge not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Badge retired"})
+}
+
+// GET /badges/progress?user_id=<id> shows a user's earned badges and how close they
+// are to each one they don't have yet (defaults to yourself)
+func (h *SocialHandler) BadgeProgress(w http.ResponseWriter, r *http.Request) {
+	requesterID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	targetID := requesterID
+	if idStr := r.URL.Query().Get("user_id"); idStr != "" {
+		var err error
+		if targetID, err = primitive.ObjectIDFromHex(idStr); err != nil {
+			http.Error(w, `{"error": "Invalid user ID"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var user models.User
+	if err := h.DB.Collection("users").FindOne(context.Background(), bson.M{"_id": targetID}).Decode(&user); err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+
+	catalog, err := helpers.BadgeCatalog(h.DB, true)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch badge catalog"}`, http.StatusInternalServerError)
+		return
+	}
+	counters, err := helpers.UserBadgeCounters(h.DB, user)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to compute counters"}`, http.StatusInternalServerError)
+		return
+	}
+
+	cursor, err := h.DB.Collection("Badges").Find(context.Background(), bson.M{"user_id": targetID})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch badges"}`, http.StatusInternalServerError)
+		return
+	}
+	earned := []models.Badge{}
+	if err := cursor.All(context.Background(), &earned); err != nil {
+		http.Error(w, `{"error": "Failed to parse badges"}`, http.StatusInternalServerError)
+		return
+	}
+	has := make(map[string]bool)
+	for _, b := range earned {
+		has[b.Name] = true
+	}
+
+	progress := []helpers.BadgeProgress{}
+	for _, def := range catalog {
+		if !has[def.Name] {
+			progress = append(progress, helpers.ProgressTowards(def, counters))
+		}
+	}
+	// closest badges first
+	sort.SliceStable(progress, func(i, j int) bool { return progress[i].Percent > progress[j].Percent })
+
+	type EarnedBadge struct {
+		Name     string    `json:"name"`
+		Type     string    `json:"type"`
+		Icon     string    `json:"icon,omitempty"`
+		EarnedAt time.Time `json:"earned_at"`
+	}
+	earnedList := []EarnedBadge{}
+	for _, b := range earned {
+		earnedList = append(earnedList, EarnedBadge{Name: b.Name, Type: b.Type, Icon: b.Icon, EarnedAt: b.CreatedAt})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"counters": counters,
+		"earned":   earnedList,
+		"progress": progress,
+	})
+}
+
+// POST /award-badge lets the admin give a reader a custom badge, for an event or a competition
+func (h *SocialHandler) AwardBadge(w http.ResponseWriter, r *http.Request) {
+	adminID, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		UserID      string `json:"user_id"`
+		ReaderID    string `json:"reader_id"`
+		BadgeName   string `json:"badge_name"`
+		Description string `json:"description"`
+		Icon        string `json:"icon"`
+		Score       int    `json:"score"`
+		Reason      string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	if input.BadgeName == "" || input.Reason == "" {
+		http.Error(w, `{"error": "badge_name and reason are required"}`, http.StatusBadRequest)
+		return
+	}
+	if input.Score < 0 {
+		http.Error(w, `{"error": "score cannot be negative"}`, http.StatusBadRequest)
+		return
+	}
+
+	if input.UserID == "" && input.ReaderID == "" {
+		http.Error(w, `{"error": "user_id or reader_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.findUserByIDOrReaderID(input.UserID, input.ReaderID)
+	if err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+
+	badgesCol := h.DB.Collection("Badges")
+	if count, _ := badgesCol.CountDocuments(context.Background(), bson.M{"user_id": user.ID, "name": input.BadgeName}); count > 0 {
+		http.Error(w, `{"error": "User already has this badge"}`, http.StatusConflict)
+		return
+	}
+
+	description := input.Description
+	if description == "" {
+		description = "Awarded the " + input.BadgeName + " badge!"
+	}
+	res, err := badgesCol.InsertOne(context.Background(), models.Badge{
+		UserID:      user.ID,
+		Name:        input.BadgeName,
+		Description: description,
+		Type:        "custom",
+		Icon:        input.Icon,
+		CreatedAt:   time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, `{"error": "User already has this badge"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to award badge"}`, http.StatusInternalServerError)
+		return
+	}
+	badgeID := res.InsertedID.(primitive.ObjectID)
+
+	if err := helpers.UpdateRankScore(h.DB, user.ID, input.Score, helpers.ScoreReasonBadgeAwarded, "badge", badgeID); err != nil {
+		log.Printf("failed to score awarded badge %s: %v", badgeID.Hex(), err)
+	}
+	_, _ = h.DB.Collection("BadgeAudits").InsertOne(context.Background(), models.BadgeAudit{
+		Action:     "award",
+		UserID:     user.ID,
+		AdminID:    adminID,
+		BadgeID:    badgeID,
+		BadgeName:  input.BadgeName,
+		Reason:     input.Reason,
+		ScoreDelta: input.Score,
+		CreatedAt:  time.Now(),
+	})
+	go helpers.CreateNotification(h.DB, user.ID, adminID, badgeID, "badge_awarded")
+	go helpers.RecordActivity(h.DB, user.ID, "badge_earned", primitive.NilObjectID, badgeID, input.BadgeName)
+	h.Events.Publish(helpers.DomainEvent{
+		Type:     helpers.EventBadgeEarned,
+		UserID:   user.ID,
+		ActorID:  adminID,
+		TargetID: badgeID,
+	})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":  "Badge awarded",
+		"badge_id": badgeID,
+	})
+}
+
+// POST /revoke-badge lets the admin take a badge away (for example one earned by
+// gaming the system); the points it gave are taken back
+func (h *SocialHandler) RevokeBadge(w http.ResponseWriter, r *http.Request) {
+	adminID, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		BadgeID string `json:"badge_id"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	if input.Reason == "" {
+		http.Error(w, `{"error": "reason is required"}`, http.StatusBadRequest)
+		return
+	}
+	badgeID, err := primitive.ObjectIDFromHex(input.BadgeID)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid badge ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	badgesCol := h.DB.Collection("Badges")
+	var badge models.Badge
+	if err := badgesCol.FindOne(context.Background(), bson.M{"_id": badgeID}).Decode(&badge); err != nil {
+		http.Error(w, `{"error": "Badge not found"}`, http.StatusNotFound)
+		return
+	}
+
+	score, err := helpers.BadgeScore(h.DB, badgeID, badge.Name)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to look up badge score"}`, http.StatusInternalServerError)
+		return
+	}
+	if _, err := badgesCol.DeleteOne(context.Background(), bson.M{"_id": badgeID}); err != nil {
+		http.Error(w, `{"error": "Failed to revoke badge"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if err := helpers.UpdateRankScore(h.DB, badge.UserID, -score, helpers.ScoreReasonBadgeRevoked, "badge", badgeID); err != nil {
+		log.Printf("failed to take back score of badge %s: %v", badgeID.Hex(), err)
+	}
+	_, _ = h.DB.Collection("BadgeAudits").InsertOne(context.Background(), models.BadgeAudit{
+		Action:     "revoke",
+		UserID:     badge.UserID,
+		AdminID:    adminID,
+		BadgeID:    badgeID,
+		BadgeName:  badge.Name,
+		Reason:     input.Reason,
+		ScoreDelta: -score,
+		CreatedAt:  time.Now(),
+	})
+	go helpers.CreateNotification(h.DB, badge.UserID, adminID, badgeID, "badge_revoked")
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":     "Badge revoked",
+		"score_delta": -score,
+	})
+}
+
+// GET /badge-audits?user_id=<id> is the admin log of awarded and revoked badges
+func (h *SocialHandler) BadgeAudits(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	filter := bson.M{}
+	if idStr := r.URL.Query().Get("user_id"); idStr != "" {
+		userID, err := primitive.ObjectIDFromHex(idStr)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid user ID"}`, http.StatusBadRequest)
+			return
+		}
+		filter["user_id"] = userID
+	}
+
+	cursor, err := h.DB.Collection("BadgeAudits").Find(context.Background(), filter,
+		options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(200))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch audit log"}`, http.StatusInternalServerError)
+		return
+	}
+	audits := []models.BadgeAudit{}
+	if err := cursor.All(context.Background(), &audits); err != nil {
+		http.Error(w, `{"error": "Failed to parse audit log"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"count":  len(audits),
+		"audits": audits,
+	})
+}
+
+// GET /class-tag-history?user_id=<id> shows the tier changes of a reader and the
+// tiers they can reach (defaults to yourself)
+func (h *SocialHandler) ClassTagHistory(w http.ResponseWriter, r *http.Request) {
+	requesterID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	targetID := requesterID
+	if idStr := r.URL.Query().Get("user_id"); idStr != "" {
+		var err error
+		if targetID, err = primitive.ObjectIDFromHex(idStr); err != nil {
+			http.Error(w, `{"error": "Invalid user ID"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var user models.User
+	if err := h.DB.Collection("users").FindOne(context.Background(), bson.M{"_id": targetID}).Decode(&user); err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+
+	cursor, err := h.DB.Collection("ClassTagHistory").Find(context.Background(), bson.M{"user_id": targetID},
+		options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch class tag history"}`, http.StatusInternalServerError)
+		return
+	}
+	history := []models.ClassTagChange{}
+	if err := cursor.All(context.Background(), &history); err != nil {
+		http.Error(w, `{"error": "Failed to parse class tag history"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"class_tag": user.ClassTag,
+		"tiers":     helpers.ClassTagTiers(),
+		"history":   history,
+	})
+}
@@ -8,18 +8,21 @@ import (
 	"os"
 	"reading-tracker/backend/helpers"
 	"reading-tracker/backend/models"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 
 )
 
 type BookHandler struct {
-	DB *mongo.Database
+	DB     *mongo.Database
+	Events *helpers.EventBus
 }
 
 // this function will enable the admin to add new book to the available books- working correctly
@@ -295,6 +298,18 @@ func (h *BookHandler) BorrowBook(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
+
+	// Readers with overdue books have to return them first
+	blocked, err := helpers.BorrowBlocked(h.DB, studentID)
+	if err != nil {
+		http.Error(w, "error while checking your loans", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		http.Error(w, "you have overdue books, return them before borrowing another", http.StatusForbidden)
+		return
+	}
+
 	_, err = books.UpdateOne(
 		context.Background(),
 		bson.M{"isbn": input.ISBN},
@@ -308,6 +323,7 @@ func (h *BookHandler) BorrowBook(w http.ResponseWriter, r *http.Request) {
 
 	// Record borrowing history
 	borrows := h.DB.Collection("BorrowHistory")
+	dueDate := time.Now().AddDate(0, 0, helpers.LoanDays())
 	_, err = borrows.InsertOne(context.Background(), bson.M{
 		"isbn":        book.ISBN,
 		"title":       book.Title,
@@ -315,6 +331,7 @@ func (h *BookHandler) BorrowBook(w http.ResponseWriter, r *http.Request) {
 		"reader_id":   user.ReaderID,
 		"book_id":     book.ID,
 		"borrow_date": time.Now(),
+		"due_date":    dueDate,
 		"type":        book.Type,
 	})
 	if err != nil {
@@ -347,9 +364,16 @@ for adminsCursor.Next(context.Background()) {
 		http.Error(w, "failed to record reading", http.StatusInternalServerError)
 		return
 	}
+	go helpers.RecordActivity(h.DB, studentID, "started_reading", book.ID, primitive.NilObjectID, "")
+	h.Events.Publish(helpers.DomainEvent{
+		Type:     helpers.EventBookBorrowed,
+		UserID:   studentID,
+		ActorID:  studentID,
+		TargetID: book.ID,
+	})
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"location": book.PhysicalLocation, "phone_number": book.PhoneNumberOfTheHandler})
+	json.NewEncoder(w).Encode(map[string]string{"location": book.PhysicalLocation, "phone_number": book.PhoneNumberOfTheHandler, "due_date": dueDate.Format("2006-01-02")})
 }
 
 // this hanlder will add softcopy books to the reading list
@@ -451,6 +475,7 @@ func (h *BookHandler) AddToReading(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to record", http.StatusInternalServerError)
 		return
 	}
+	go helpers.RecordActivity(h.DB, userid, "started_reading", book.ID, primitive.NilObjectID, "")
 
 	// now tell the user that he has added the book to the reading db
 	w.WriteHeader(http.StatusOK)
@@ -558,6 +583,13 @@ func (h *BookHandler) ReturnBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.Events.Publish(helpers.DomainEvent{
+		Type:     helpers.EventBookReturned,
+		UserID:   record.UserID,
+		ActorID:  userID,
+		TargetID: book.ID,
+	})
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Book returned successfully"})
 }
@@ -630,12 +662,25 @@ func (h *BookHandler) UpdateReadingProgress(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Check existing progress
+	var book_original models.Book
+	books := h.DB.Collection("books")
+
+	if err := books.FindOne(context.Background(), bson.M{"isbn": input.ISBN}).Decode(&book_original); err != nil {
+		http.Error(w, "error while fetching the book!", http.StatusInternalServerError)
+		return
+	}
+
+	if book_original.TotalPages < input.PagesRead {
+		http.Error(w, "no of pages you read cannot be greater than pages of the book!", http.StatusConflict)
+		return
+	}
+
+	// Check existing progress (stored against the book, not the reading entry)
 	readingProgress := h.DB.Collection("ReadingProgress")
 	var progress models.ReadingProgress
 	err = readingProgress.FindOne(context.Background(), bson.M{
 		"user_id": userID,
-		"book_id": book.ID,
+		"book_id": book_original.ID,
 	}).Decode(&progress)
 
 	// Calculate streak (simple: increment if updated today or yesterday)
@@ -656,17 +701,19 @@ func (h *BookHandler) UpdateReadingProgress(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	var book_original models.Book
-	books := h.DB.Collection("books")
-
-	if err := books.FindOne(context.Background(), bson.M{"isbn": input.ISBN}).Decode(&book_original); err != nil {
-		http.Error(w, "error while fetching the book!", http.StatusInternalServerError)
-		return
+	// Share milestones (25%, 50%...) with followers
+	previousPages := 0
+	if err == nil {
+		previousPages = progress.PagesRead
+	}
+	if milestone := helpers.CrossedMilestone(previousPages, input.PagesRead, book_original.TotalPages); milestone > 0 {
+		go helpers.RecordActivity(h.DB, userID, "progress_milestone", book_original.ID, primitive.NilObjectID, strconv.Itoa(milestone)+"%")
 	}
 
-	if book_original.TotalPages < input.PagesRead {
-		http.Error(w, "no of pages you read cannot be greater than pages of the book!", http.StatusConflict)
-		return
+	// Where the reader stands against the schedules of their book clubs
+	response := map[string]any{}
+	if schedules, err := helpers.ClubScheduleForReader(h.DB, userID, book_original.ID, input.PagesRead); err == nil && len(schedules) > 0 {
+		response["club_schedule"] = schedules
 	}
 
 	// Update or create progress
@@ -691,12 +738,22 @@ func (h *BookHandler) UpdateReadingProgress(w http.ResponseWriter, r *http.Reque
 		}
 
 		// ✅ Award points if streak increased
+		event := helpers.DomainEvent{
+			Type:     helpers.EventProgressUpdated,
+			UserID:   userID,
+			ActorID:  userID,
+			TargetID: book_original.ID,
+		}
 		if streakIncreased {
-		  helpers.UpdateRankScore(h.DB, userID, 1)
+			event.Score = []helpers.ScoreChange{{
+				UserID: userID, Delta: 1, Reason: helpers.ScoreReasonReadingStreak, SourceType: "reading_progress", SourceID: progress.ID,
+			}}
 		}
+		h.Events.Publish(event)
 
+		response["message"] = "Progress updated"
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Progress updated"})
+		json.NewEncoder(w).Encode(response)
 	} else {
 		// Create new
 		_, err = readingProgress.InsertOne(context.Background(), models.ReadingProgress{
@@ -723,13 +780,20 @@ func (h *BookHandler) UpdateReadingProgress(w http.ResponseWriter, r *http.Reque
 			http.Error(w, "Error while updating reading", http.StatusInternalServerError)
 			return
 		}
-        // just update the badge
-		helpers.UpdateUserBadgesAndClassTag(userID,h.DB)
-		// ✅ New progress starts with streak → award points
-		helpers.UpdateRankScore(h.DB, userID, 1)
+		// ✅ New progress starts with streak → award points, and update the badges
+		h.Events.Publish(helpers.DomainEvent{
+			Type:     helpers.EventProgressUpdated,
+			UserID:   userID,
+			ActorID:  userID,
+			TargetID: book_original.ID,
+			Score: []helpers.ScoreChange{{
+				UserID: userID, Delta: 1, Reason: helpers.ScoreReasonReadingStarted, SourceType: "book", SourceID: book_original.ID,
+			}},
+		})
 
+		response["message"] = "Progress created"
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Progress created"})
+		json.NewEncoder(w).Encode(response)
 	}
 }
 
@@ -776,8 +840,9 @@ func (h *BookHandler) SubmitReview(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var input struct {
-		ISBN       string `json:"isbn"`
-		ReviewText string `json:"review_text"`
+		ISBN       string   `json:"isbn"`
+		ReviewText string   `json:"review_text"`
+		Rating     *float64 `json:"rating"` // optional, 1-5 with half stars
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
@@ -789,6 +854,14 @@ func (h *BookHandler) SubmitReview(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing ISBN or review text", http.StatusBadRequest)
 		return
 	}
+	rating := 0.0
+	if input.Rating != nil {
+		if err := helpers.ValidateRating(*input.Rating); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rating = *input.Rating
+	}
 
 	// Find book
 	books := h.DB.Collection("books")
@@ -827,12 +900,20 @@ func (h *BookHandler) SubmitReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Compare against similar reviews so the moderator can spot copies
+	similarity, err := helpers.CheckReviewSimilarity(h.DB, userID, book.ID, input.ReviewText)
+	if err != nil {
+		http.Error(w, "Failed to check review similarity", http.StatusInternalServerError)
+		return
+	}
+
 	// Create review
 	res, err := reviews.InsertOne(context.Background(), models.Review{
 		BookID:        book.ID,
 		UserID:        userID,
 		ReaderID:      user.ReaderID,
 		ReviewText:    input.ReviewText,
+		Rating:        rating,
 		AICheckStatus: "pending",
 		AIScore:       0,
 		Posted:        false,
@@ -840,7 +921,16 @@ func (h *BookHandler) SubmitReview(w http.ResponseWriter, r *http.Request) {
 		Upvotes:       0,
 		CreatedAt:     time.Now(),
 		UpvotedBy: []primitive.ObjectID{},
+		MinHash:        similarity.Signature,
+		MinHashBands:   similarity.Bands,
+		SimilarReviews: similarity.Matches,
+		Flagged:        similarity.Flagged,
+		FlagReason:     similarity.FlagReason,
 	})
+	if err != nil {
+		http.Error(w, "Failed to submit review", http.StatusInternalServerError)
+		return
+	}
 	reviewID := res.InsertedID.(primitive.ObjectID)
 
 // Notify all admins about the new review submission
@@ -852,13 +942,76 @@ for adminsCursor.Next(context.Background()) {
     helpers.CreateNotification(h.DB, admin.ID, userID, reviewID, "new_review")
 }
 
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Review submitted"})
+}
+
+// GET /pending-reviews lets the admin see reviews waiting for moderation together
+// with the most similar existing reviews, flagged copies first
+func (h *BookHandler) ListPendingReviews(w http.ResponseWriter, r *http.Request) {
+	tokenString := r.Header.Get("Authorization")
+	if tokenString == "" {
+		http.Error(w, `{"error": "Missing token"}`, http.StatusUnauthorized)
+		return
+	}
+	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
+		tokenString = tokenString[7:]
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["role"] != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	reviewsCol := h.DB.Collection("Reviews")
+	cursor, err := reviewsCol.Find(context.Background(), bson.M{
+		"ai_check_status": "pending",
+		"book_deleted":    false,
+	}, options.Find().SetSort(bson.D{{Key: "flagged", Value: -1}, {Key: "created_at", Value: 1}}))
 	if err != nil {
-		http.Error(w, "Failed to submit review", http.StatusInternalServerError)
+		http.Error(w, `{"error": "Failed to fetch pending reviews"}`, http.StatusInternalServerError)
 		return
 	}
+	defer cursor.Close(context.Background())
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Review submitted"})
+	var pending []models.Review
+	if err := cursor.All(context.Background(), &pending); err != nil {
+		http.Error(w, `{"error": "Failed to decode pending reviews"}`, http.StatusInternalServerError)
+		return
+	}
+
+	booksCol := h.DB.Collection("books")
+	results := []map[string]any{}
+	for _, rev := range pending {
+		var book models.Book
+		_ = booksCol.FindOne(context.Background(), bson.M{"_id": rev.BookID}).Decode(&book)
+
+		results = append(results, map[string]any{
+			"review_id":       rev.ID,
+			"reader_id":       rev.ReaderID,
+			"book_title":      book.Title,
+			"isbn":            book.ISBN,
+			"review_text":     rev.ReviewText,
+			"flagged":         rev.Flagged,
+			"flag_reason":     rev.FlagReason,
+			"similar_reviews": rev.SimilarReviews,
+			"created_at":      rev.CreatedAt,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"count":   len(results),
+		"reviews": results,
+	})
 }
 
 // this will approv the book review by the admin
@@ -940,10 +1093,22 @@ func (h *BookHandler) ApproveReview(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// ===== Update user stats if approved =====
-	if input.Status == "approved" {
+	// a review edited after approval comes back here; its author was already credited
+	firstApproval := input.Status == "approved" && !review.ApprovedBefore
+	if firstApproval {
 		_, _ = usersCol.UpdateOne(context.Background(), bson.M{"_id": review.UserID}, bson.M{
 			"$inc": bson.M{"books_read": 1},
 		})
+		_, _ = reviewsCol.UpdateOne(context.Background(), bson.M{"_id": reviewID}, bson.M{
+			"$set": bson.M{"approved_before": true},
+		})
+	}
+	if input.Status == "approved" && !review.Hidden {
+		// only approved reviews that aren't hidden count towards the book's rating
+		if err := helpers.AddBookRating(h.DB, review.BookID, review.Rating); err != nil {
+			http.Error(w, "Failed to update book rating", http.StatusInternalServerError)
+			return
+		}
 	}
 booksCol := h.DB.Collection("books")
 var book models.Book
@@ -997,12 +1162,20 @@ if res.MatchedCount == 0 {
 
 
 	// ===== Update badges & rank =====
-	if input.Status == "approved" {
-		helpers.UpdateUserBadgesAndClassTag(review.UserID, h.DB)
-		helpers.UpdateRankScore(h.DB, review.UserID, 5)
+	event := helpers.DomainEvent{
+		Type:     helpers.EventReviewApproved,
+		UserID:   review.UserID,
+		TargetID: review.ID,
+	}
+	// the approval finishes the book, so the feed entry and points are only given once
+	if firstApproval {
+		go helpers.RecordActivity(h.DB, review.UserID, "review_approved", review.BookID, review.ID, "")
+		event.Score = []helpers.ScoreChange{
+			{UserID: review.UserID, Delta: helpers.ReviewApprovedPoints, Reason: helpers.ScoreReasonReviewApproved, SourceType: "review", SourceID: review.ID},
+			{UserID: review.UserID, Delta: helpers.BookCompletedPoints, Reason: helpers.ScoreReasonBookCompleted, SourceType: "book", SourceID: review.BookID},
+		}
+		h.Events.Publish(event)
 	}
-	// Always give rank for finishing book
-	helpers.UpdateRankScore(h.DB, review.UserID, 10)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
@@ -1590,6 +1763,8 @@ func (h *BookHandler) SearchBooks(w http.ResponseWriter, r *http.Request) {
     author := r.URL.Query().Get("author")
     genre := r.URL.Query().Get("genre")
     available := r.URL.Query().Get("available")
+    sortBy := r.URL.Query().Get("sort") // "rating" for best rated first
+    minRating := r.URL.Query().Get("min_rating")
     fmt.Println(title)
 	fmt.Println(author)
     filter := bson.M{}
@@ -1609,10 +1784,21 @@ func (h *BookHandler) SearchBooks(w http.ResponseWriter, r *http.Request) {
         } else if available == "false" {
             filter["available"] = false
         }
+    }
+    if minRating != "" {
+        if parsed, err := strconv.ParseFloat(minRating, 64); err == nil {
+            filter["rating_average"] = bson.M{"$gte": parsed}
+        }
     }
 	fmt.Println(filter)
+
+    findOptions := options.Find()
+    if sortBy == "rating" {
+        findOptions.SetSort(bson.D{{Key: "rating_average", Value: -1}, {Key: "rating_count", Value: -1}})
+    }
+
 	book := h.DB.Collection("books")
-    cursor, err := book.Find(context.Background(), filter)
+    cursor, err := book.Find(context.Background(), filter, findOptions)
     if err != nil {
         http.Error(w, `{"error":"Failed to fetch books"}`, http.StatusInternalServerError)
         return
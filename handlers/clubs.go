@@ -0,0 +1,796 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ClubHandler struct {
+	DB *mongo.Database
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+// loadClub reads the {id} route variable and fetches that club
+func (h *ClubHandler) loadClub(w http.ResponseWriter, r *http.Request) (models.Club, bool) {
+	var club models.Club
+	clubID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid club ID"}`, http.StatusBadRequest)
+		return club, false
+	}
+	if err := h.DB.Collection("Clubs").FindOne(context.Background(), bson.M{"_id": clubID}).Decode(&club); err != nil {
+		http.Error(w, `{"error": "Club not found"}`, http.StatusNotFound)
+		return club, false
+	}
+	return club, true
+}
+
+func canModerate(club models.Club, userID primitive.ObjectID) bool {
+	return club.OwnerID == userID || containsID(club.Moderators, userID)
+}
+
+// POST /clubs creates a club with the caller as owner
+func (h *ClubHandler) CreateClub(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	if input.Name == "" {
+		http.Error(w, `{"error": "Club name is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.DB.Collection("Clubs").InsertOne(context.Background(), models.Club{
+		Name:        input.Name,
+		Description: input.Description,
+		OwnerID:     userID,
+		Moderators:  []primitive.ObjectID{},
+		Members:     []primitive.ObjectID{userID},
+		Schedule:    []models.ClubMilestone{},
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to create club"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": "Club created",
+		"club_id": res.InsertedID,
+	})
+}
+
+// GET /clubs lists all clubs with their current book
+func (h *ClubHandler) ListClubs(w http.ResponseWriter, r *http.Request) {
+	cursor, err := h.DB.Collection("Clubs").Find(context.Background(), bson.M{},
+		options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch clubs"}`, http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var clubs []models.Club
+	if err := cursor.All(context.Background(), &clubs); err != nil {
+		http.Error(w, `{"error": "Failed to parse clubs"}`, http.StatusInternalServerError)
+		return
+	}
+
+	results := []map[string]any{}
+	for _, c := range clubs {
+		var book models.Book
+		if !c.BookID.IsZero() {
+			_ = h.DB.Collection("books").FindOne(context.Background(), bson.M{"_id": c.BookID}).Decode(&book)
+		}
+		results = append(results, map[string]any{
+			"id":           c.ID,
+			"name":         c.Name,
+			"description":  c.Description,
+			"member_count": len(c.Members),
+			"book_title":   book.Title,
+			"isbn":         c.ISBN,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"count": len(results),
+		"clubs": results,
+	})
+}
+
+// GET /clubs/{id} shows a club, its schedule and, for members, where they stand
+func (h *ClubHandler) GetClub(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	club, ok := h.loadClub(w, r)
+	if !ok {
+		return
+	}
+
+	members := []map[string]any{}
+	cursor, err := h.DB.Collection("users").Find(context.Background(), bson.M{"_id": bson.M{"$in": club.Members}})
+	if err == nil {
+		var users []models.User
+		if err := cursor.All(context.Background(), &users); err == nil {
+			for _, u := range users {
+				role := "member"
+				if u.ID == club.OwnerID {
+					role = "owner"
+				} else if containsID(club.Moderators, u.ID) {
+					role = "moderator"
+				}
+				members = append(members, map[string]any{
+					"id":        u.ID,
+					"name":      u.Name,
+					"reader_id": u.ReaderID,
+					"role":      role,
+				})
+			}
+		}
+	}
+
+	response := map[string]any{
+		"id":          club.ID,
+		"name":        club.Name,
+		"description": club.Description,
+		"isbn":        club.ISBN,
+		"schedule":    club.Schedule,
+		"members":     members,
+	}
+
+	if !club.BookID.IsZero() {
+		var book models.Book
+		if err := h.DB.Collection("books").FindOne(context.Background(), bson.M{"_id": club.BookID}).Decode(&book); err == nil {
+			response["book"] = map[string]any{
+				"title":       book.Title,
+				"author":      book.Author,
+				"total_pages": book.TotalPages,
+			}
+		}
+		if containsID(club.Members, userID) {
+			response["your_progress"] = helpers.ScheduleStatus(club, h.pagesRead(userID, club.BookID), time.Now())
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// pagesRead is how far a reader is in a book according to ReadingProgress
+func (h *ClubHandler) pagesRead(userID, bookID primitive.ObjectID) int {
+	var progress models.ReadingProgress
+	if err := h.DB.Collection("ReadingProgress").FindOne(context.Background(), bson.M{
+		"user_id": userID,
+		"book_id": bookID,
+	}, options.FindOne().SetSort(bson.D{{Key: "pages_read", Value: -1}, {Key: "last_updated", Value: -1}})).Decode(&progress); err != nil {
+		return 0
+	}
+	return progress.PagesRead
+}
+
+// POST /clubs/{id}/book sets the club's current book and its milestone schedule
+func (h *ClubHandler) SetClubBook(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	club, ok := h.loadClub(w, r)
+	if !ok {
+		return
+	}
+	if !canModerate(club, userID) {
+		http.Error(w, `{"error": "Only the owner or a moderator can change the book"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		ISBN     string `json:"isbn"`
+		Schedule []struct {
+			ID      string    `json:"id"` // keeps an existing milestone and its discussion
+			Title   string    `json:"title"`
+			Page    int       `json:"page"`
+			Chapter string    `json:"chapter"`
+			DueDate time.Time `json:"due_date"`
+		} `json:"schedule"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+
+	var book models.Book
+	if err := h.DB.Collection("books").FindOne(context.Background(), bson.M{"isbn": input.ISBN}).Decode(&book); err != nil {
+		http.Error(w, `{"error": "Book not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// Milestones of the same book keep their ids so their discussion threads stay attached;
+	// one picked by id, or else the first one on the same page, is carried over
+	existing := map[primitive.ObjectID]bool{}
+	if club.BookID == book.ID {
+		for _, m := range club.Schedule {
+			existing[m.ID] = true
+		}
+	}
+	kept := map[primitive.ObjectID]bool{}
+	keepID := func(idHex string, page int) (primitive.ObjectID, bool) {
+		if idHex != "" {
+			id, err := primitive.ObjectIDFromHex(idHex)
+			if err != nil || !existing[id] || kept[id] {
+				return primitive.NilObjectID, false
+			}
+			kept[id] = true
+			return id, true
+		}
+		for _, m := range club.Schedule {
+			if existing[m.ID] && !kept[m.ID] && m.Page == page {
+				kept[m.ID] = true
+				return m.ID, true
+			}
+		}
+		return primitive.NewObjectID(), true
+	}
+
+	schedule := []models.ClubMilestone{}
+	for _, m := range input.Schedule {
+		if m.Page <= 0 || (book.TotalPages > 0 && m.Page > book.TotalPages) {
+			http.Error(w, `{"error": "Every milestone needs a page within the book"}`, http.StatusBadRequest)
+			return
+		}
+		if m.DueDate.IsZero() {
+			http.Error(w, `{"error": "Every milestone needs a due_date"}`, http.StatusBadRequest)
+			return
+		}
+		id, ok := keepID(m.ID, m.Page)
+		if !ok {
+			http.Error(w, `{"error": "Unknown or repeated milestone id"}`, http.StatusBadRequest)
+			return
+		}
+		schedule = append(schedule, models.ClubMilestone{
+			ID:      id,
+			Title:   m.Title,
+			Page:    m.Page,
+			Chapter: m.Chapter,
+			DueDate: m.DueDate,
+		})
+	}
+	sort.Slice(schedule, func(i, j int) bool { return schedule[i].Page < schedule[j].Page })
+
+	// A milestone that is dropped must not take a discussion with it
+	var dropped []primitive.ObjectID
+	for id := range existing {
+		if !kept[id] {
+			dropped = append(dropped, id)
+		}
+	}
+	if len(dropped) > 0 {
+		if count, _ := h.DB.Collection("ClubPosts").CountDocuments(context.Background(), bson.M{
+			"club_id": club.ID, "milestone_id": bson.M{"$in": dropped},
+		}); count > 0 {
+			http.Error(w, `{"error": "Milestones with posts cannot be removed from the schedule"}`, http.StatusConflict)
+			return
+		}
+	}
+
+	_, err := h.DB.Collection("Clubs").UpdateOne(context.Background(), bson.M{"_id": club.ID}, bson.M{
+		"$set": bson.M{
+			"book_id":  book.ID,
+			"isbn":     book.ISBN,
+			"schedule": schedule,
+		},
+	})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update club"}`, http.StatusInternalServerError)
+		return
+	}
+
+	for _, memberID := range club.Members {
+		go helpers.CreateNotification(h.DB, memberID, userID, club.ID, "club_new_book")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":  "Club book updated",
+		"schedule": schedule,
+	})
+}
+
+// POST /clubs/{id}/moderators lets the owner promote (or with remove, demote) a member
+func (h *ClubHandler) SetModerator(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	club, ok := h.loadClub(w, r)
+	if !ok {
+		return
+	}
+	if club.OwnerID != userID {
+		http.Error(w, `{"error": "Only the owner can change moderators"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		UserID string `json:"user_id"`
+		Remove bool   `json:"remove"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	memberID, err := primitive.ObjectIDFromHex(input.UserID)
+	if err != nil || !containsID(club.Members, memberID) {
+		http.Error(w, `{"error": "Moderators must be club members"}`, http.StatusBadRequest)
+		return
+	}
+
+	update := bson.M{"$addToSet": bson.M{"moderators": memberID}}
+	if input.Remove {
+		update = bson.M{"$pull": bson.M{"moderators": memberID}}
+	}
+	if _, err := h.DB.Collection("Clubs").UpdateOne(context.Background(), bson.M{"_id": club.ID}, update); err != nil {
+		http.Error(w, `{"error": "Failed to update moderators"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Moderators updated"})
+}
+
+// POST /clubs/{id}/invite lets a moderator invite a reader by reader_id
+func (h *ClubHandler) InviteToClub(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	club, ok := h.loadClub(w, r)
+	if !ok {
+		return
+	}
+	if !canModerate(club, userID) {
+		http.Error(w, `{"error": "Only the owner or a moderator can invite"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		ReaderID string `json:"reader_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	if input.ReaderID == "" {
+		http.Error(w, `{"error": "reader_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	var invitee models.User
+	if err := h.DB.Collection("users").FindOne(context.Background(), bson.M{"reader_id": input.ReaderID, "verified": true}).Decode(&invitee); err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if containsID(club.Members, invitee.ID) {
+		http.Error(w, `{"error": "Already a member"}`, http.StatusConflict)
+		return
+	}
+
+	invitesCol := h.DB.Collection("ClubInvites")
+	if count, _ := invitesCol.CountDocuments(context.Background(), bson.M{
+		"club_id": club.ID, "user_id": invitee.ID, "status": "pending",
+	}); count > 0 {
+		http.Error(w, `{"error": "An invitation or request is already pending"}`, http.StatusConflict)
+		return
+	}
+
+	res, err := invitesCol.InsertOne(context.Background(), models.ClubInvite{
+		ClubID:    club.ID,
+		UserID:    invitee.ID,
+		InvitedBy: userID,
+		Kind:      "invite",
+		Status:    "pending",
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to invite"}`, http.StatusInternalServerError)
+		return
+	}
+	go helpers.CreateNotification(h.DB, invitee.ID, userID, res.InsertedID.(primitive.ObjectID), "club_invite")
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":   "Invitation sent",
+		"invite_id": res.InsertedID,
+	})
+}
+
+// POST /clubs/{id}/join sends a join request, or accepts a pending invitation
+func (h *ClubHandler) JoinClub(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	club, ok := h.loadClub(w, r)
+	if !ok {
+		return
+	}
+	if containsID(club.Members, userID) {
+		http.Error(w, `{"error": "Already a member"}`, http.StatusConflict)
+		return
+	}
+
+	invitesCol := h.DB.Collection("ClubInvites")
+	var pending models.ClubInvite
+	err := invitesCol.FindOne(context.Background(), bson.M{
+		"club_id": club.ID, "user_id": userID, "status": "pending",
+	}).Decode(&pending)
+	if err == nil {
+		if pending.Kind == "invite" {
+			if err := h.acceptInvite(pending); err != nil {
+				http.Error(w, `{"error": "Failed to join club"}`, http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Joined " + club.Name})
+			return
+		}
+		http.Error(w, `{"error": "Join request already pending"}`, http.StatusConflict)
+		return
+	}
+
+	res, err := invitesCol.InsertOne(context.Background(), models.ClubInvite{
+		ClubID:    club.ID,
+		UserID:    userID,
+		Kind:      "request",
+		Status:    "pending",
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to send join request"}`, http.StatusInternalServerError)
+		return
+	}
+	for _, modID := range append([]primitive.ObjectID{club.OwnerID}, club.Moderators...) {
+		go helpers.CreateNotification(h.DB, modID, userID, res.InsertedID.(primitive.ObjectID), "club_join_request")
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":    "Join request sent",
+		"request_id": res.InsertedID,
+	})
+}
+
+func (h *ClubHandler) acceptInvite(invite models.ClubInvite) error {
+	_, err := h.DB.Collection("Clubs").UpdateOne(context.Background(), bson.M{"_id": invite.ClubID}, bson.M{
+		"$addToSet": bson.M{"members": invite.UserID},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = h.DB.Collection("ClubInvites").UpdateOne(context.Background(), bson.M{"_id": invite.ID}, bson.M{
+		"$set": bson.M{"status": "accepted"},
+	})
+	return err
+}
+
+// GET /clubs/{id}/requests lists pending invitations and join requests for moderators
+func (h *ClubHandler) ListClubRequests(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	club, ok := h.loadClub(w, r)
+	if !ok {
+		return
+	}
+	if !canModerate(club, userID) {
+		http.Error(w, `{"error": "Only the owner or a moderator can see requests"}`, http.StatusForbidden)
+		return
+	}
+
+	cursor, err := h.DB.Collection("ClubInvites").Find(context.Background(), bson.M{"club_id": club.ID, "status": "pending"})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch requests"}`, http.StatusInternalServerError)
+		return
+	}
+	invites := []models.ClubInvite{}
+	if err := cursor.All(context.Background(), &invites); err != nil {
+		http.Error(w, `{"error": "Failed to parse requests"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"count":    len(invites),
+		"requests": invites,
+	})
+}
+
+// POST /club-invites/{id}/respond accepts or declines an invitation (as the invitee)
+// or a join request (as a club moderator)
+func (h *ClubHandler) RespondToInvite(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	inviteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid invite ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		Accept bool `json:"accept"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+
+	var invite models.ClubInvite
+	if err := h.DB.Collection("ClubInvites").FindOne(context.Background(), bson.M{"_id": inviteID, "status": "pending"}).Decode(&invite); err != nil {
+		http.Error(w, `{"error": "Pending invitation not found"}`, http.StatusNotFound)
+		return
+	}
+	var club models.Club
+	if err := h.DB.Collection("Clubs").FindOne(context.Background(), bson.M{"_id": invite.ClubID}).Decode(&club); err != nil {
+		http.Error(w, `{"error": "Club not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if (invite.Kind == "invite" && invite.UserID != userID) || (invite.Kind == "request" && !canModerate(club, userID)) {
+		http.Error(w, `{"error": "You cannot answer this invitation"}`, http.StatusForbidden)
+		return
+	}
+
+	if input.Accept {
+		err = h.acceptInvite(invite)
+	} else {
+		_, err = h.DB.Collection("ClubInvites").UpdateOne(context.Background(), bson.M{"_id": invite.ID}, bson.M{
+			"$set": bson.M{"status": "declined"},
+		})
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to answer invitation"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if invite.Kind == "request" {
+		notifType := "club_request_declined"
+		if input.Accept {
+			notifType = "club_request_accepted"
+		}
+		go helpers.CreateNotification(h.DB, invite.UserID, userID, club.ID, notifType)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Invitation answered"})
+}
+
+// POST /clubs/{id}/leave removes the caller from a club; the owner cannot leave
+func (h *ClubHandler) LeaveClub(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	club, ok := h.loadClub(w, r)
+	if !ok {
+		return
+	}
+	if club.OwnerID == userID {
+		http.Error(w, `{"error": "The owner cannot leave the club"}`, http.StatusBadRequest)
+		return
+	}
+	if !containsID(club.Members, userID) {
+		http.Error(w, `{"error": "You are not a member"}`, http.StatusBadRequest)
+		return
+	}
+
+	_, err := h.DB.Collection("Clubs").UpdateOne(context.Background(), bson.M{"_id": club.ID}, bson.M{
+		"$pull": bson.M{"members": userID, "moderators": userID},
+	})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to leave club"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Left " + club.Name})
+}
+
+// milestoneAccess finds the {milestone} of a club and checks the caller is a member who
+// has read far enough to see its discussion without spoilers
+func (h *ClubHandler) milestoneAccess(w http.ResponseWriter, r *http.Request) (models.Club, models.ClubMilestone, primitive.ObjectID, bool) {
+	var milestone models.ClubMilestone
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return models.Club{}, milestone, userID, false
+	}
+	club, ok := h.loadClub(w, r)
+	if !ok {
+		return club, milestone, userID, false
+	}
+	if !containsID(club.Members, userID) {
+		http.Error(w, `{"error": "Only members can see club discussions"}`, http.StatusForbidden)
+		return club, milestone, userID, false
+	}
+
+	milestoneID, err := primitive.ObjectIDFromHex(mux.Vars(r)["milestone"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid milestone ID"}`, http.StatusBadRequest)
+		return club, milestone, userID, false
+	}
+	found := false
+	for _, m := range club.Schedule {
+		if m.ID == milestoneID {
+			milestone, found = m, true
+			break
+		}
+	}
+	if !found {
+		http.Error(w, `{"error": "Milestone not found"}`, http.StatusNotFound)
+		return club, milestone, userID, false
+	}
+
+	if pages := h.pagesRead(userID, club.BookID); pages < milestone.Page {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{
+			"error":        "Spoilers! Reach the milestone page to unlock this discussion",
+			"unlock_page":  milestone.Page,
+			"your_page":    pages,
+			"milestone_id": milestone.ID,
+		})
+		return club, milestone, userID, false
+	}
+	return club, milestone, userID, true
+}
+
+// unlockedMembers are the club members whose progress has reached a milestone
+func (h *ClubHandler) unlockedMembers(club models.Club, milestone models.ClubMilestone) []primitive.ObjectID {
+	ids, err := h.DB.Collection("ReadingProgress").Distinct(context.Background(), "user_id", bson.M{
+		"book_id":    club.BookID,
+		"user_id":    bson.M{"$in": club.Members},
+		"pages_read": bson.M{"$gte": milestone.Page},
+	})
+	if err != nil {
+		log.Printf("failed to load members who reached milestone %s: %v", milestone.ID.Hex(), err)
+		return nil
+	}
+	members := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if memberID, ok := id.(primitive.ObjectID); ok {
+			members = append(members, memberID)
+		}
+	}
+	return members
+}
+
+// GET /clubs/{id}/milestones/{milestone}/posts shows a milestone discussion to members who reached it
+func (h *ClubHandler) ListMilestonePosts(w http.ResponseWriter, r *http.Request) {
+	club, milestone, _, ok := h.milestoneAccess(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, err := h.DB.Collection("ClubPosts").Find(context.Background(),
+		bson.M{"club_id": club.ID, "milestone_id": milestone.ID},
+		options.Find().SetSort(bson.M{"created_at": 1}),
+	)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch posts"}`, http.StatusInternalServerError)
+		return
+	}
+	var posts []models.ClubPost
+	if err := cursor.All(context.Background(), &posts); err != nil {
+		http.Error(w, `{"error": "Failed to parse posts"}`, http.StatusInternalServerError)
+		return
+	}
+
+	names := make(map[primitive.ObjectID]models.User)
+	results := []map[string]any{}
+	for _, p := range posts {
+		user, seen := names[p.UserID]
+		if !seen {
+			_ = h.DB.Collection("users").FindOne(context.Background(), bson.M{"_id": p.UserID}).Decode(&user)
+			names[p.UserID] = user
+		}
+		results = append(results, map[string]any{
+			"id":         p.ID,
+			"user_name":  user.Name,
+			"reader_id":  user.ReaderID,
+			"text":       p.Text,
+			"created_at": p.CreatedAt,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"milestone": milestone,
+		"count":     len(results),
+		"posts":     results,
+	})
+}
+
+// POST /clubs/{id}/milestones/{milestone}/posts adds to a milestone discussion
+func (h *ClubHandler) AddMilestonePost(w http.ResponseWriter, r *http.Request) {
+	club, milestone, userID, ok := h.milestoneAccess(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Text == "" {
+		http.Error(w, `{"error": "text is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.DB.Collection("ClubPosts").InsertOne(context.Background(), models.ClubPost{
+		ClubID:      club.ID,
+		MilestoneID: milestone.ID,
+		UserID:      userID,
+		Text:        input.Text,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to post"}`, http.StatusInternalServerError)
+		return
+	}
+	// only members who can already read the discussion hear about it, so the
+	// notification can't spoil the book for anyone else
+	postID := res.InsertedID.(primitive.ObjectID)
+	go func() {
+		if err := helpers.NotifyMentionsAmong(h.DB, userID, postID, input.Text, h.unlockedMembers(club, milestone)); err != nil {
+			log.Printf("failed to notify mentions in club post %s: %v", postID.Hex(), err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": "Posted",
+		"post_id": res.InsertedID,
+	})
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CommentNode is one comment with its replies nested under it
+type CommentNode struct {
+	ID        primitive.ObjectID `json:"id"`
+	UserID    primitive.ObjectID `json:"user_id"`
+	UserName  string             `json:"user_name"`
+	ReaderID  string             `json:"reader_id"`
+	Text      string             `json:"text"`
+	Upvotes   int                `json:"upvotes"`
+	Depth     int                `json:"depth"`
+	Hidden    bool               `json:"hidden,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	EditedAt  time.Time          `json:"edited_at,omitempty"`
+	Replies   []*CommentNode     `json:"replies"`
+}
+
+// GET /comments?type=review|quote&id=<id> returns the comment tree of a review or quote
+func (h *SocialHandler) CommentThread(w http.ResponseWriter, r *http.Request) {
+	var collection, parentField string
+	switch r.URL.Query().Get("type") {
+	case "review":
+		collection, parentField = "ReviewComments", "review_id"
+	case "quote":
+		collection, parentField = "QuoteComments", "quote_id"
+	default:
+		http.Error(w, `{"error": "type must be review or quote"}`, http.StatusBadRequest)
+		return
+	}
+
+	targetID, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Invalid id"}`, http.StatusBadRequest)
+		return
+	}
+
+	cursor, err := h.DB.Collection(collection).Find(context.Background(),
+		bson.M{parentField: targetID},
+		options.Find().SetSort(bson.M{"created_at": 1}),
+	)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch comments"}`, http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var comments []struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		ParentID  primitive.ObjectID `bson:"parent_id"`
+		UserID    primitive.ObjectID `bson:"user_id"`
+		Text      string             `bson:"text"`
+		Upvotes   int                `bson:"upvotes"`
+		Depth     int                `bson:"depth"`
+		Hidden    bool               `bson:"hidden"`
+		CreatedAt time.Time          `bson:"created_at"`
+		EditedAt  time.Time          `bson:"edited_at"`
+	}
+	if err := cursor.All(context.Background(), &comments); err != nil {
+		http.Error(w, `{"error": "Failed to parse comments"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// ===== Look up the authors in one query =====
+	authorIDs := []primitive.ObjectID{}
+	for _, c := range comments {
+		authorIDs = append(authorIDs, c.UserID)
+	}
+	type author struct {
+		ID       primitive.ObjectID `bson:"_id"`
+		Name     string             `bson:"name"`
+		ReaderID string             `bson:"reader_id"`
+	}
+	authors := make(map[primitive.ObjectID]author)
+	if len(authorIDs) > 0 {
+		usersCursor, err := h.DB.Collection("users").Find(context.Background(), bson.M{"_id": bson.M{"$in": authorIDs}})
+		if err == nil {
+			var found []author
+			if err := usersCursor.All(context.Background(), &found); err == nil {
+				for _, a := range found {
+					authors[a.ID] = a
+				}
+			}
+		}
+	}
+
+	// ===== Build the tree =====
+	nodes := make(map[primitive.ObjectID]*CommentNode, len(comments))
+	for _, c := range comments {
+		node := &CommentNode{
+			ID:        c.ID,
+			UserID:    c.UserID,
+			UserName:  authors[c.UserID].Name,
+			ReaderID:  authors[c.UserID].ReaderID,
+			Text:      c.Text,
+			Upvotes:   c.Upvotes,
+			Depth:     c.Depth,
+			CreatedAt: c.CreatedAt,
+			EditedAt:  c.EditedAt,
+			Replies:   []*CommentNode{},
+		}
+		if c.Hidden {
+			// keep the place in the thread so replies still make sense
+			node.Hidden = true
+			node.Text = "[hidden]"
+		}
+		nodes[c.ID] = node
+	}
+
+	roots := []*CommentNode{}
+	for _, c := range comments {
+		node := nodes[c.ID]
+		if parent, ok := nodes[c.ParentID]; ok && !c.ParentID.IsZero() {
+			parent.Replies = append(parent.Replies, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"count":    len(comments),
+		"comments": roots,
+	})
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// contentKind describes one type of user text that can be edited or deleted
+type contentKind struct {
+	Type       string // revision content_type
+	Collection string
+	IDField    string // json field carrying the id in the request
+	Label      string
+}
+
+var (
+	reviewCommentKind = contentKind{Type: "review_comment", Collection: "ReviewComments", IDField: "comment_id", Label: "Comment"}
+	quoteKind         = contentKind{Type: "quote", Collection: "Quotes", IDField: "quote_id", Label: "Quote"}
+	quoteCommentKind  = contentKind{Type: "quote_comment", Collection: "QuoteComments", IDField: "comment_id", Label: "Comment"}
+
+	// contentCollections maps a content_type to the collection holding it
+	contentCollections = map[string]string{
+		"review":         "Reviews",
+		"review_comment": reviewCommentKind.Collection,
+		"quote":          quoteKind.Collection,
+		"quote_comment":  quoteCommentKind.Collection,
+	}
+)
+
+// requesterFromToken reads the user id and role from the Authorization header
+func requesterFromToken(r *http.Request) (primitive.ObjectID, string, bool) {
+	tokenString := r.Header.Get("Authorization")
+	if tokenString == "" {
+		return primitive.NilObjectID, "", false
+	}
+	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
+		tokenString = tokenString[7:]
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return primitive.NilObjectID, "", false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return primitive.NilObjectID, "", false
+	}
+	idStr, _ := claims["user_id"].(string)
+	userID, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		return primitive.NilObjectID, "", false
+	}
+	role, _ := claims["role"].(string)
+	return userID, role, true
+}
+
+// POST /edit-review lets the author (or an admin) fix a review; an approved review
+// edited by its author goes back to the moderation queue
+func (h *SocialHandler) EditReview(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		ReviewID   string   `json:"review_id"`
+		ReviewText string   `json:"review_text"`
+		Rating     *float64 `json:"rating"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	if input.ReviewText == "" {
+		http.Error(w, `{"error": "review_text is required"}`, http.StatusBadRequest)
+		return
+	}
+	reviewID, err := primitive.ObjectIDFromHex(input.ReviewID)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid review ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	reviewsCol := h.DB.Collection("Reviews")
+	var review models.Review
+	if err := reviewsCol.FindOne(context.Background(), bson.M{"_id": reviewID}).Decode(&review); err != nil {
+		http.Error(w, `{"error": "Review not found"}`, http.StatusNotFound)
+		return
+	}
+
+	isOwner := review.UserID == userID
+	if !isOwner && role != "admin" {
+		http.Error(w, `{"error": "You can only edit your own review"}`, http.StatusForbidden)
+		return
+	}
+
+	rating := review.Rating
+	if input.Rating != nil {
+		if err := helpers.ValidateRating(*input.Rating); err != nil {
+			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusBadRequest)
+			return
+		}
+		rating = *input.Rating
+	}
+
+	if err := helpers.RecordRevision(h.DB, "review", review.ID, userID, review.ReviewText, review.Rating); err != nil {
+		http.Error(w, `{"error": "Failed to save revision"}`, http.StatusInternalServerError)
+		return
+	}
+
+	similarity, err := helpers.CheckReviewSimilarity(h.DB, review.UserID, review.BookID, input.ReviewText)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to check review similarity"}`, http.StatusInternalServerError)
+		return
+	}
+
+	set := bson.M{
+		"review_text":     input.ReviewText,
+		"rating":          rating,
+		"minhash":         similarity.Signature,
+		"minhash_bands":   similarity.Bands,
+		"similar_reviews": similarity.Matches,
+		"flagged":         similarity.Flagged,
+		"flag_reason":     similarity.FlagReason,
+		"edited_at":       time.Now(),
+	}
+
+	backToModeration := isOwner && review.Posted
+	if backToModeration {
+		set["posted"] = false
+		set["ai_check_status"] = "pending"
+	}
+
+	if _, err := reviewsCol.UpdateOne(context.Background(), bson.M{"_id": review.ID}, bson.M{"$set": set}); err != nil {
+		http.Error(w, `{"error": "Failed to update review"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if review.Posted && !review.Hidden {
+		// the old rating no longer counts; the new one is added on (re)approval
+		_ = helpers.RemoveBookRating(h.DB, review.BookID, review.Rating)
+		if !backToModeration {
+			_ = helpers.AddBookRating(h.DB, review.BookID, rating)
+		}
+	}
+
+	message := "Review updated"
+	if backToModeration {
+		message = "Review updated and sent back for approval"
+		adminsCursor, _ := h.DB.Collection("users").Find(context.Background(), bson.M{"role": "admin"})
+		for adminsCursor.Next(context.Background()) {
+			var admin models.User
+			_ = adminsCursor.Decode(&admin)
+			helpers.CreateNotification(h.DB, admin.ID, userID, review.ID, "edited_review")
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// DELETE /delete-review removes a review together with its comments and notifications
+func (h *SocialHandler) DeleteReview(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		ReviewID string `json:"review_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	reviewID, err := primitive.ObjectIDFromHex(input.ReviewID)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid review ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var review models.Review
+	if err := h.DB.Collection("Reviews").FindOne(context.Background(), bson.M{"_id": reviewID}).Decode(&review); err != nil {
+		http.Error(w, `{"error": "Review not found"}`, http.StatusNotFound)
+		return
+	}
+	if review.UserID != userID && role != "admin" {
+		http.Error(w, `{"error": "You can only delete your own review"}`, http.StatusForbidden)
+		return
+	}
+
+	if err := helpers.DeleteReviewCascade(h.DB, reviewID); err != nil {
+		log.Printf("failed to delete review %s: %v", reviewID.Hex(), err)
+		http.Error(w, `{"error": "Failed to delete review"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Review deleted"})
+}
+
+// POST /edit-review-comment
+func (h *SocialHandler) EditReviewComment(w http.ResponseWriter, r *http.Request) {
+	h.editTextContent(w, r, reviewCommentKind)
+}
+
+// DELETE /delete-review-comment
+func (h *SocialHandler) DeleteReviewComment(w http.ResponseWriter, r *http.Request) {
+	h.deleteTextContent(w, r, reviewCommentKind)
+}
+
+// POST /edit-quote
+func (h *SocialHandler) EditQuote(w http.ResponseWriter, r *http.Request) {
+	h.editTextContent(w, r, quoteKind)
+}
+
+// DELETE /delete-quote
+func (h *SocialHandler) DeleteQuote(w http.ResponseWriter, r *http.Request) {
+	h.deleteTextContent(w, r, quoteKind)
+}
+
+// POST /edit-quote-comment
+func (h *SocialHandler) EditQuoteComment(w http.ResponseWriter, r *http.Request) {
+	h.editTextContent(w, r, quoteCommentKind)
+}
+
+// DELETE /delete-quote-comment
+func (h *SocialHandler) DeleteQuoteComment(w http.ResponseWriter, r *http.Request) {
+	h.deleteTextContent(w, r, quoteCommentKind)
+}
+
+// editTextContent updates the text of a quote or comment and keeps the old text as a revision
+func (h *SocialHandler) editTextContent(w http.ResponseWriter, r *http.Request, kind contentKind) {
+	userID, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var input map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	if input["text"] == "" {
+		http.Error(w, `{"error": "text is required"}`, http.StatusBadRequest)
+		return
+	}
+	contentID, err := primitive.ObjectIDFromHex(input[kind.IDField])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid `+kind.IDField+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	col := h.DB.Collection(kind.Collection)
+	var content struct {
+		UserID primitive.ObjectID `bson:"user_id"`
+		Text   string             `bson:"text"`
+	}
+	if err := col.FindOne(context.Background(), bson.M{"_id": contentID}).Decode(&content); err != nil {
+		http.Error(w, `{"error": "`+kind.Label+` not found"}`, http.StatusNotFound)
+		return
+	}
+	if content.UserID != userID && role != "admin" {
+		http.Error(w, `{"error": "You can only edit your own content"}`, http.StatusForbidden)
+		return
+	}
+
+	if err := helpers.RecordRevision(h.DB, kind.Type, contentID, userID, content.Text, 0); err != nil {
+		http.Error(w, `{"error": "Failed to save revision"}`, http.StatusInternalServerError)
+		return
+	}
+
+	_, err = col.UpdateOne(context.Background(), bson.M{"_id": contentID}, bson.M{
+		"$set": bson.M{"text": input["text"], "edited_at": time.Now()},
+	})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update `+kind.Type+`"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": kind.Label + " updated"})
+}
+
+// deleteTextContent removes a quote or comment and everything hanging off it
+func (h *SocialHandler) deleteTextContent(w http.ResponseWriter, r *http.Request, kind contentKind) {
+	userID, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var input map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	contentID, err := primitive.ObjectIDFromHex(input[kind.IDField])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid `+kind.IDField+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	var content struct {
+		UserID primitive.ObjectID `bson:"user_id"`
+	}
+	if err := h.DB.Collection(kind.Collection).FindOne(context.Background(), bson.M{"_id": contentID}).Decode(&content); err != nil {
+		http.Error(w, `{"error": "`+kind.Label+` not found"}`, http.StatusNotFound)
+		return
+	}
+	if content.UserID != userID && role != "admin" {
+		http.Error(w, `{"error": "You can only delete your own content"}`, http.StatusForbidden)
+		return
+	}
+
+	if kind == quoteKind {
+		err = helpers.DeleteQuoteCascade(h.DB, contentID)
+	} else {
+		err = helpers.DeleteCommentCascade(h.DB, kind.Collection, contentID)
+	}
+	if err != nil {
+		log.Printf("failed to delete %s %s: %v", kind.Type, contentID.Hex(), err)
+		http.Error(w, `{"error": "Failed to delete `+kind.Type+`"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": kind.Label + " deleted"})
+}
+
+// GET /content-revisions?type=review&id=<id> shows the edit history to the author or an admin
+func (h *SocialHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	contentType := r.URL.Query().Get("type")
+	collection, known := contentCollections[contentType]
+	if !known {
+		http.Error(w, `{"error": "type must be review, review_comment, quote or quote_comment"}`, http.StatusBadRequest)
+		return
+	}
+	contentID, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Invalid content ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var content struct {
+		UserID primitive.ObjectID `bson:"user_id"`
+	}
+	if err := h.DB.Collection(collection).FindOne(context.Background(), bson.M{"_id": contentID}).Decode(&content); err != nil {
+		http.Error(w, `{"error": "Content not found"}`, http.StatusNotFound)
+		return
+	}
+	if content.UserID != userID && role != "admin" {
+		http.Error(w, `{"error": "Only the author or an admin can see revisions"}`, http.StatusForbidden)
+		return
+	}
+
+	cursor, err := h.DB.Collection("Revisions").Find(context.Background(),
+		bson.M{"content_type": contentType, "content_id": contentID},
+		options.Find().SetSort(bson.M{"edited_at": -1}),
+	)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch revisions"}`, http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	revisions := []models.Revision{}
+	if err := cursor.All(context.Background(), &revisions); err != nil {
+		http.Error(w, `{"error": "Failed to parse revisions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"count":     len(revisions),
+		"revisions": revisions,
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// featuredDetails loads the featured item with its author and book for the home screen;
+// mongo.ErrNoDocuments means it was deleted or hidden since it was picked
+func (h *SocialHandler) featuredDetails(pick models.FeaturedPick) (map[string]any, error) {
+	var text string
+	var userID, bookID primitive.ObjectID
+	details := map[string]any{
+		"id":     pick.ContentID,
+		"period": pick.Period,
+		"pinned": pick.Pinned,
+	}
+
+	if pick.Kind == "quote" {
+		var quote models.Quote
+		if err := h.DB.Collection("Quotes").FindOne(context.Background(), bson.M{"_id": pick.ContentID, "hidden": bson.M{"$ne": true}}).Decode(&quote); err != nil {
+			return nil, err
+		}
+		text, userID, bookID = quote.Text, quote.UserID, quote.BookID
+		details["upvotes"] = quote.Upvotes
+		details["page"] = quote.Page
+		details["chapter"] = quote.Chapter
+	} else {
+		var review models.Review
+		if err := h.DB.Collection("Reviews").FindOne(context.Background(), bson.M{"_id": pick.ContentID, "hidden": bson.M{"$ne": true}}).Decode(&review); err != nil {
+			return nil, err
+		}
+		text, userID, bookID = review.ReviewText, review.UserID, review.BookID
+		details["upvotes"] = review.Upvotes
+		details["rating"] = review.Rating
+	}
+	details["text"] = text
+
+	var user models.User
+	if err := h.DB.Collection("users").FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user); err == nil {
+		details["author"] = map[string]string{
+			"name":      user.Name,
+			"reader_id": user.ReaderID,
+			"class_tag": user.ClassTag,
+		}
+	}
+
+	if !bookID.IsZero() {
+		var book models.Book
+		if err := h.DB.Collection("books").FindOne(context.Background(), bson.M{"_id": bookID}).Decode(&book); err == nil {
+			details["book"] = map[string]any{
+				"title":          book.Title,
+				"author":         book.Author,
+				"isbn":           book.ISBN,
+				"rating_average": book.RatingAverage,
+			}
+		}
+	}
+	return details, nil
+}
+
+// GET /featured returns the quote of the day and the review of the week
+func (h *SocialHandler) Featured(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	response := map[string]any{
+		"quote_of_the_day":   nil,
+		"review_of_the_week": nil,
+	}
+
+	for kind, key := range map[string]string{"quote": "quote_of_the_day", "review": "review_of_the_week"} {
+		pick, err := helpers.CurrentFeatured(h.DB, kind, now)
+		if err == helpers.ErrNothingToFeature {
+			continue
+		}
+		if err != nil {
+			log.Printf("failed to select featured %s: %v", kind, err)
+			http.Error(w, `{"error": "Failed to load featured content"}`, http.StatusInternalServerError)
+			return
+		}
+		details, err := h.featuredDetails(pick)
+		if err == mongo.ErrNoDocuments {
+			// the featured item was deleted or hidden since it was picked
+			if pick, err = helpers.ReplaceFeatured(h.DB, pick, now); err == nil {
+				details, err = h.featuredDetails(pick)
+			}
+		}
+		if err != nil {
+			if err != helpers.ErrNothingToFeature && err != mongo.ErrNoDocuments {
+				log.Printf("failed to load featured %s: %v", kind, err)
+			}
+			continue
+		}
+		response[key] = details
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// POST /featured/pin lets an admin choose the current quote of the day or review of
+// the week, or hand the choice back to the selector with "unpin"
+func (h *SocialHandler) PinFeatured(w http.ResponseWriter, r *http.Request) {
+	adminID, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		Kind      string `json:"kind"` // "quote" or "review"
+		ContentID string `json:"content_id"`
+		Unpin     bool   `json:"unpin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	if input.Kind != "quote" && input.Kind != "review" {
+		http.Error(w, `{"error": "kind must be quote or review"}`, http.StatusBadRequest)
+		return
+	}
+
+	if input.Unpin {
+		if err := helpers.UnpinFeatured(h.DB, input.Kind, time.Now()); err != nil {
+			http.Error(w, `{"error": "Failed to unpin"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Featured " + input.Kind + " unpinned"})
+		return
+	}
+
+	contentID, err := primitive.ObjectIDFromHex(input.ContentID)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid content ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	filter := bson.M{"_id": contentID, "hidden": bson.M{"$ne": true}}
+	collection := "Quotes"
+	if input.Kind == "review" {
+		collection = "Reviews"
+		filter["posted"] = true
+	}
+	if count, _ := h.DB.Collection(collection).CountDocuments(context.Background(), filter); count == 0 {
+		http.Error(w, `{"error": "Content not found or not public"}`, http.StatusNotFound)
+		return
+	}
+
+	if err := helpers.PinFeatured(h.DB, input.Kind, contentID, adminID, time.Now()); err != nil {
+		http.Error(w, `{"error": "Failed to pin"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Featured " + input.Kind + " pinned"})
+}
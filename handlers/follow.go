@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// findUserByIDOrReaderID looks a user up by hex id or reader_id, whichever is given.
+// With neither it finds nobody, instead of a user without a reader_id such as the admin
+func (h *SocialHandler) findUserByIDOrReaderID(userIDStr, readerID string) (models.User, error) {
+	var user models.User
+	if userIDStr == "" && readerID == "" {
+		return user, mongo.ErrNoDocuments
+	}
+	filter := bson.M{"reader_id": readerID, "verified": true}
+	if userIDStr != "" {
+		userID, err := primitive.ObjectIDFromHex(userIDStr)
+		if err != nil {
+			return user, err
+		}
+		filter = bson.M{"_id": userID, "verified": true}
+	}
+	err := h.DB.Collection("users").FindOne(context.Background(), filter).Decode(&user)
+	return user, err
+}
+
+// POST /follow starts following another reader (by user_id or reader_id)
+func (h *SocialHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		UserID   string `json:"user_id"`
+		ReaderID string `json:"reader_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+
+	if input.UserID == "" && input.ReaderID == "" {
+		http.Error(w, `{"error": "user_id or reader_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	target, err := h.findUserByIDOrReaderID(input.UserID, input.ReaderID)
+	if err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if target.ID == userID {
+		http.Error(w, `{"error": "You cannot follow yourself"}`, http.StatusBadRequest)
+		return
+	}
+
+	followsCol := h.DB.Collection("Follows")
+	res, err := followsCol.UpdateOne(context.Background(),
+		bson.M{"follower_id": userID, "followee_id": target.ID},
+		bson.M{"$setOnInsert": bson.M{"created_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to follow"}`, http.StatusInternalServerError)
+		return
+	}
+	if res.UpsertedCount == 0 {
+		http.Error(w, `{"error": "Already following"}`, http.StatusConflict)
+		return
+	}
+
+	go helpers.CreateNotification(h.DB, target.ID, userID, userID, "new_follower")
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Now following " + target.ReaderID})
+}
+
+// POST /unfollow stops following a reader
+func (h *SocialHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		UserID   string `json:"user_id"`
+		ReaderID string `json:"reader_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+
+	if input.UserID == "" && input.ReaderID == "" {
+		http.Error(w, `{"error": "user_id or reader_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	target, err := h.findUserByIDOrReaderID(input.UserID, input.ReaderID)
+	if err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+
+	res, err := h.DB.Collection("Follows").DeleteOne(context.Background(), bson.M{
+		"follower_id": userID,
+		"followee_id": target.ID,
+	})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to unfollow"}`, http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.Error(w, `{"error": "You are not following this user"}`, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Unfollowed " + target.ReaderID})
+}
+
+// GET /followers?user_id=<id> lists who follows a user (defaults to yourself)
+func (h *SocialHandler) Followers(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, "followee_id", "follower_id", "followers")
+}
+
+// GET /following?user_id=<id> lists who a user follows (defaults to yourself)
+func (h *SocialHandler) Following(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, "follower_id", "followee_id", "following")
+}
+
+func (h *SocialHandler) listFollows(w http.ResponseWriter, r *http.Request, matchField, otherField, key string) {
+	requesterID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	targetID := requesterID
+	if idStr := r.URL.Query().Get("user_id"); idStr != "" {
+		var err error
+		if targetID, err = primitive.ObjectIDFromHex(idStr); err != nil {
+			http.Error(w, `{"error": "Invalid user ID"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	ids, err := h.DB.Collection("Follows").Distinct(context.Background(), otherField, bson.M{matchField: targetID})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch follows"}`, http.StatusInternalServerError)
+		return
+	}
+
+	type FollowUser struct {
+		ID        primitive.ObjectID `json:"id"`
+		Name      string             `json:"name"`
+		ReaderID  string             `json:"reader_id"`
+		ClassTag  string             `json:"class_tag"`
+		RankScore int                `json:"rank_score"`
+	}
+	users := []FollowUser{}
+	if len(ids) > 0 {
+		cursor, err := h.DB.Collection("users").Find(context.Background(), bson.M{"_id": bson.M{"$in": ids}},
+			options.Find().SetSort(bson.M{"name": 1}))
+		if err != nil {
+			http.Error(w, `{"error": "Failed to fetch users"}`, http.StatusInternalServerError)
+			return
+		}
+		defer cursor.Close(context.Background())
+		for cursor.Next(context.Background()) {
+			var u models.User
+			if err := cursor.Decode(&u); err != nil {
+				continue
+			}
+			users = append(users, FollowUser{
+				ID:        u.ID,
+				Name:      u.Name,
+				ReaderID:  u.ReaderID,
+				ClassTag:  u.ClassTag,
+				RankScore: u.RankScore,
+			})
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"count": len(users),
+		key:     users,
+	})
+}
+
+// GET /feed?page=1&limit=20 is the timeline of activity from the readers you follow
+func (h *SocialHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	page, limit := int64(1), int64(20)
+	if p, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	following, err := h.DB.Collection("Follows").Distinct(context.Background(), "followee_id", bson.M{"follower_id": userID})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch follows"}`, http.StatusInternalServerError)
+		return
+	}
+	if len(following) == 0 {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{"page": page, "limit": limit, "total": 0, "activities": []any{}})
+		return
+	}
+
+	activitiesCol := h.DB.Collection("Activities")
+	filter := bson.M{"user_id": bson.M{"$in": following}, "hidden": bson.M{"$ne": true}}
+	total, err := activitiesCol.CountDocuments(context.Background(), filter)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to count activities"}`, http.StatusInternalServerError)
+		return
+	}
+
+	cursor, err := activitiesCol.Find(context.Background(), filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch feed"}`, http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var activities []models.Activity
+	if err := cursor.All(context.Background(), &activities); err != nil {
+		http.Error(w, `{"error": "Failed to parse feed"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// ===== Attach names and titles =====
+	users := make(map[primitive.ObjectID]models.User)
+	books := make(map[primitive.ObjectID]models.Book)
+	items := []map[string]any{}
+	for _, a := range activities {
+		user, seen := users[a.UserID]
+		if !seen {
+			_ = h.DB.Collection("users").FindOne(context.Background(), bson.M{"_id": a.UserID}).Decode(&user)
+			users[a.UserID] = user
+		}
+		item := map[string]any{
+			"id":         a.ID,
+			"type":       a.Type,
+			"user_name":  user.Name,
+			"reader_id":  user.ReaderID,
+			"target_id":  a.TargetID,
+			"detail":     a.Detail,
+			"created_at": a.CreatedAt,
+		}
+		if !a.BookID.IsZero() {
+			book, seen := books[a.BookID]
+			if !seen {
+				_ = h.DB.Collection("books").FindOne(context.Background(), bson.M{"_id": a.BookID}).Decode(&book)
+				books[a.BookID] = book
+			}
+			item["book_title"] = book.Title
+			item["isbn"] = book.ISBN
+		}
+		items = append(items, item)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"activities": items,
+	})
+}
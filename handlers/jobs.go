@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GET /jobs lists the scheduled jobs with their spec, next run and last run
+func (h *SocialHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"jobs": h.Jobs.Jobs()})
+}
+
+// POST /jobs/{name}/run starts a job now, whatever its schedule says
+func (h *SocialHandler) RunJob(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	run, err := h.Jobs.RunNow(mux.Vars(r)["name"])
+	switch err {
+	case nil:
+	case helpers.ErrUnknownJob:
+		http.Error(w, `{"error": "Job not found"}`, http.StatusNotFound)
+		return
+	case helpers.ErrJobRunning:
+		http.Error(w, `{"error": "Job is already running"}`, http.StatusConflict)
+		return
+	default:
+		http.Error(w, `{"error": "Failed to start job"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": "Job started",
+		"run":     run,
+	})
+}
+
+// GET /jobs/{name}/runs?page=1&limit=20 is the run history of a job, newest first
+func (h *SocialHandler) JobRuns(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if !h.Jobs.HasJob(name) {
+		http.Error(w, `{"error": "Job not found"}`, http.StatusNotFound)
+		return
+	}
+	page, limit := int64(1), int64(20)
+	if p, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	filter := bson.M{"job": name}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter["status"] = status
+	}
+	runsCol := h.DB.Collection("JobRuns")
+	total, err := runsCol.CountDocuments(context.Background(), filter)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to count runs"}`, http.StatusInternalServerError)
+		return
+	}
+	cursor, err := runsCol.Find(context.Background(), filter, options.Find().
+		SetSort(bson.M{"started_at": -1}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch runs"}`, http.StatusInternalServerError)
+		return
+	}
+	runs := []models.JobRun{}
+	if err := cursor.All(context.Background(), &runs); err != nil {
+		http.Error(w, `{"error": "Failed to decode runs"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"runs":  runs,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GET /notification-preferences returns the delivery mode of every notification type for the caller
+func (h *SocialHandler) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var prefs models.NotificationPreferences
+	_ = h.DB.Collection("NotificationPreferences").FindOne(context.Background(), bson.M{"user_id": userID}).Decode(&prefs)
+
+	types := helpers.NotificationTypes()
+	sort.Strings(types)
+	modes := make(map[string]string, len(types))
+	for _, t := range types {
+		modes[t] = helpers.NotifyInstant
+		if mode, ok := prefs.Modes[t]; ok {
+			modes[t] = mode
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"modes":         modes,
+		"types":         types,
+		"allowed_modes": []string{helpers.NotifyInstant, helpers.NotifyDigest, helpers.NotifyOff},
+	})
+}
+
+// POST /notification-preferences sets the delivery mode of some notification types,
+// body {"modes": {"upvote_review": "digest", "new_follower": "off"}}
+func (h *SocialHandler) SaveNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		Modes map[string]string `json:"modes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Modes) == 0 {
+		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	known := make(map[string]bool)
+	for _, t := range helpers.NotificationTypes() {
+		known[t] = true
+	}
+	set := bson.M{"updated_at": time.Now()}
+	for t, mode := range body.Modes {
+		if !known[t] {
+			http.Error(w, `{"error": "Unknown notification type"}`, http.StatusBadRequest)
+			return
+		}
+		if mode != helpers.NotifyInstant && mode != helpers.NotifyDigest && mode != helpers.NotifyOff {
+			http.Error(w, `{"error": "Mode must be instant, digest or off"}`, http.StatusBadRequest)
+			return
+		}
+		set["modes."+t] = mode
+	}
+
+	_, err := h.DB.Collection("NotificationPreferences").UpdateOne(context.Background(),
+		bson.M{"user_id": userID},
+		bson.M{"$set": set, "$setOnInsert": bson.M{"user_id": userID}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to save preferences"}`, http.StatusInternalServerError)
+		return
+	}
+
+	h.GetNotificationPreferences(w, r)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// how often an idle stream gets a comment line so proxies keep it open
+const streamHeartbeat = 25 * time.Second
+
+// writeNotificationEvent sends one rendered notification as an SSE event whose id is the notification id
+func (h *SocialHandler) writeNotificationEvent(w http.ResponseWriter, n models.Notification) error {
+	data, err := json.Marshal(helpers.RenderNotification(h.DB, n))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: notification\ndata: %s\n\n", n.ID.Hex(), data)
+	return err
+}
+
+// GET /notifications/stream pushes new notifications as Server-Sent Events. Browsers'
+// EventSource can't set headers, so the token may also come as ?token=. A reconnect
+// with Last-Event-ID (or ?last_event_id=) first replays what was missed
+func (h *SocialHandler) NotificationStream(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" && r.URL.Query().Get("token") != "" {
+		r.Header.Set("Authorization", "Bearer "+r.URL.Query().Get("token"))
+	}
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	// TokenGuard only checks the session when the stream opens; keep what it
+	// needs so each heartbeat can check again
+	claims, _ := parseToken(r)
+	version, _ := claims["tv"].(float64)
+	sid, _ := claims["sid"].(string)
+	sessionID, _ := primitive.ObjectIDFromHex(sid)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error": "Streaming is not supported"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// subscribe before replaying so nothing written in between is lost
+	feed, unsubscribe := helpers.Notifications.Subscribe(userID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+
+	// ===== Replay what was missed =====
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	replayed := make(map[primitive.ObjectID]bool)
+	if resumeFrom, err := primitive.ObjectIDFromHex(lastID); err == nil {
+		cursor, err := h.DB.Collection("Notifications").Find(context.Background(),
+			bson.M{"user_id": userID, "_id": bson.M{"$gt": resumeFrom}},
+			options.Find().SetSort(bson.M{"_id": 1}).SetLimit(100),
+		)
+		if err == nil {
+			var missed []models.Notification
+			if err := cursor.All(context.Background(), &missed); err == nil {
+				for _, n := range missed {
+					if h.writeNotificationEvent(w, n) != nil {
+						return
+					}
+					replayed[n.ID] = true
+				}
+			}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case n, open := <-feed:
+			if !open {
+				// we fell behind; the client reconnects and resumes from its last id
+				return
+			}
+			if replayed[n.ID] {
+				continue // already sent by the replay
+			}
+			if h.writeNotificationEvent(w, n) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if !helpers.SessionActive(h.DB, userID, sessionID, int(version)) {
+				// signed out or revoked; the reconnect is refused by TokenGuard
+				return
+			}
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// clientIP is the caller's address; X-Forwarded-For is only believed behind a proxy (TRUST_PROXY=true)
+func clientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY") == "true" {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// POST /password/forgot emails a reset link. The answer is the same whether or not
+// the email belongs to someone, so it can't be used to find accounts
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if ok, err := helpers.AllowRequest(h.DB, "forgot:ip:"+clientIP(r), 5, 15*time.Minute); err != nil || !ok {
+		http.Error(w, `{"error": "Too many requests, try again later"}`, http.StatusTooManyRequests)
+		return
+	}
+
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Email == "" {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	email := strings.TrimSpace(input.Email)
+
+	// the lookup and email happen in the background so the response time doesn't tell either
+	go func() {
+		if ok, err := helpers.AllowRequest(h.DB, "forgot:email:"+strings.ToLower(email), 3, time.Hour); err != nil || !ok {
+			return
+		}
+		var user models.User
+		if err := h.DB.Collection("users").FindOne(context.Background(), bson.M{"email": email}).Decode(&user); err != nil {
+			return
+		}
+		if err := helpers.IssuePasswordReset(h.DB, user, "forgot"); err != nil {
+			log.Printf("failed to issue password reset for %s: %v", user.ID.Hex(), err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If that email is registered, a reset link is on its way",
+	})
+}
+
+// POST /password/reset sets a new password with a token from the reset email
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if ok, err := helpers.AllowRequest(h.DB, "reset:ip:"+clientIP(r), 10, 15*time.Minute); err != nil || !ok {
+		http.Error(w, `{"error": "Too many requests, try again later"}`, http.StatusTooManyRequests)
+		return
+	}
+
+	var input struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Token == "" {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+
+	// the token is only used up once the new password is accepted
+	user, err := helpers.PasswordResetUser(h.DB, input.Token)
+	if err == helpers.ErrInvalidResetToken {
+		http.Error(w, `{"error": "Reset link is invalid or expired"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to reset password"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := helpers.ValidatePassword(input.NewPassword, user.Email); err != nil {
+		http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to hash password"}`, http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := helpers.ConsumePasswordReset(h.DB, input.Token)
+	if err == helpers.ErrInvalidResetToken {
+		http.Error(w, `{"error": "Reset link is invalid or expired"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to reset password"}`, http.StatusInternalServerError)
+		return
+	}
+
+	_, err = h.DB.Collection("users").UpdateOne(context.Background(),
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"password": string(hashedPassword), "must_change_password": false}},
+	)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update password"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// sessions started with the old password end
+	if err := helpers.RevokeAllSessions(h.DB, userID); err != nil {
+		log.Printf("failed to revoke sessions of %s after password reset: %v", userID.Hex(), err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset, you can log in now"})
+}
+
+// POST /password/force-reset lets the admin make a reader pick a new password at their
+// next login (body user_id or reader_id); send_email also mails them a reset link
+func (h *AuthHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		UserID    string `json:"user_id"`
+		ReaderID  string `json:"reader_id"`
+		SendEmail bool   `json:"send_email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	user, err := h.findUser(input.UserID, input.ReaderID)
+	if err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if _, err := h.DB.Collection("users").UpdateOne(context.Background(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"must_change_password": true}}); err != nil {
+		http.Error(w, `{"error": "Failed to update user"}`, http.StatusInternalServerError)
+		return
+	}
+	// whoever may have the password now is logged out everywhere
+	if err := helpers.RevokeAllSessions(h.DB, user.ID); err != nil {
+		http.Error(w, `{"error": "Failed to revoke sessions"}`, http.StatusInternalServerError)
+		return
+	}
+	if input.SendEmail {
+		if err := helpers.IssuePasswordReset(h.DB, user, "admin"); err != nil {
+			http.Error(w, `{"error": "Failed to send reset email"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "User has to change their password"})
+}
+
+// findUser looks a user up by id, or by reader id when no id is given
+func (h *AuthHandler) findUser(userID, readerID string) (models.User, error) {
+	filter := bson.M{"reader_id": readerID}
+	if userID != "" {
+		id, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			return models.User{}, err
+		}
+		filter = bson.M{"_id": id}
+	} else if readerID == "" {
+		return models.User{}, mongo.ErrNoDocuments
+	}
+
+	var user models.User
+	err := h.DB.Collection("users").FindOne(context.Background(), filter).Decode(&user)
+	return user, err
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reportHideThreshold is how many distinct users must report something before it is
+// hidden automatically, set with REPORT_HIDE_THRESHOLD (default 3)
+func reportHideThreshold() int64 {
+	if n, err := strconv.ParseInt(os.Getenv("REPORT_HIDE_THRESHOLD"), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return 3
+}
+
+// POST /reports lets any user flag a review, quote or comment with a reason
+func (h *SocialHandler) ReportContent(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		ContentType string `json:"content_type"`
+		ContentID   string `json:"content_id"`
+		Reason      string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	collection, known := contentCollections[input.ContentType]
+	if !known {
+		http.Error(w, `{"error": "content_type must be review, review_comment, quote or quote_comment"}`, http.StatusBadRequest)
+		return
+	}
+	if input.Reason == "" {
+		http.Error(w, `{"error": "A reason is required"}`, http.StatusBadRequest)
+		return
+	}
+	contentID, err := primitive.ObjectIDFromHex(input.ContentID)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid content ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	contentCol := h.DB.Collection(collection)
+	var content struct {
+		UserID primitive.ObjectID `bson:"user_id"`
+		Hidden bool               `bson:"hidden"`
+	}
+	if err := contentCol.FindOne(context.Background(), bson.M{"_id": contentID}).Decode(&content); err != nil {
+		http.Error(w, `{"error": "Content not found"}`, http.StatusNotFound)
+		return
+	}
+	if content.UserID == userID {
+		http.Error(w, `{"error": "You cannot report your own content"}`, http.StatusBadRequest)
+		return
+	}
+
+	reportsCol := h.DB.Collection("Reports")
+	if count, _ := reportsCol.CountDocuments(context.Background(), bson.M{
+		"content_id":  contentID,
+		"reporter_id": userID,
+		"status":      "open",
+	}); count > 0 {
+		http.Error(w, `{"error": "You already reported this"}`, http.StatusConflict)
+		return
+	}
+
+	_, err = reportsCol.InsertOne(context.Background(), models.Report{
+		ContentType: input.ContentType,
+		ContentID:   contentID,
+		AuthorID:    content.UserID,
+		ReporterID:  userID,
+		Reason:      input.Reason,
+		Status:      "open",
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to save report"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// ===== Hide automatically once enough different people reported it =====
+	reporters, err := reportsCol.Distinct(context.Background(), "reporter_id", bson.M{
+		"content_id": contentID,
+		"status":     "open",
+	})
+	if err == nil && int64(len(reporters)) >= reportHideThreshold() && !content.Hidden {
+		if err := helpers.SetContentHidden(h.DB, collection, contentID, true); err != nil {
+			log.Printf("failed to hide reported %s %s: %v", input.ContentType, contentID.Hex(), err)
+		} else {
+			go func() {
+				if err := helpers.CreateNotification(h.DB, content.UserID, primitive.NilObjectID, contentID, "content_hidden"); err != nil {
+					log.Printf("failed to notify the author of hidden %s %s: %v", input.ContentType, contentID.Hex(), err)
+				}
+			}()
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Report submitted"})
+}
+
+// GET /reports is the admin report queue, one entry per reported item (query param status, default open)
+func (h *SocialHandler) ListReports(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+
+	reportsCol := h.DB.Collection("Reports")
+	cursor, err := reportsCol.Find(context.Background(), bson.M{"status": status},
+		options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch reports"}`, http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var reports []models.Report
+	if err := cursor.All(context.Background(), &reports); err != nil {
+		http.Error(w, `{"error": "Failed to parse reports"}`, http.StatusInternalServerError)
+		return
+	}
+
+	type reportReason struct {
+		ReportID   primitive.ObjectID `json:"report_id"`
+		ReporterID primitive.ObjectID `json:"reporter_id"`
+		Reason     string             `json:"reason"`
+		CreatedAt  time.Time          `json:"created_at"`
+	}
+	type queueItem struct {
+		ContentType string             `json:"content_type"`
+		ContentID   primitive.ObjectID `json:"content_id"`
+		AuthorID    primitive.ObjectID `json:"author_id"`
+		Text        string             `json:"text"`
+		Hidden      bool               `json:"hidden"`
+		Reports     []reportReason     `json:"reports"`
+	}
+
+	// group the reports by the content they point at, oldest first
+	queue := []*queueItem{}
+	byContent := make(map[primitive.ObjectID]*queueItem)
+	for _, rep := range reports {
+		item, exists := byContent[rep.ContentID]
+		if !exists {
+			item = &queueItem{
+				ContentType: rep.ContentType,
+				ContentID:   rep.ContentID,
+				AuthorID:    rep.AuthorID,
+			}
+			var content struct {
+				Text       string `bson:"text"`
+				ReviewText string `bson:"review_text"`
+				Hidden     bool   `bson:"hidden"`
+			}
+			if err := h.DB.Collection(contentCollections[rep.ContentType]).FindOne(context.Background(), bson.M{"_id": rep.ContentID}).Decode(&content); err == nil {
+				item.Text = content.Text
+				if item.Text == "" {
+					item.Text = content.ReviewText
+				}
+				item.Hidden = content.Hidden
+			}
+			byContent[rep.ContentID] = item
+			queue = append(queue, item)
+		}
+		item.Reports = append(item.Reports, reportReason{
+			ReportID:   rep.ID,
+			ReporterID: rep.ReporterID,
+			Reason:     rep.Reason,
+			CreatedAt:  rep.CreatedAt,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"count": len(queue),
+		"queue": queue,
+	})
+}
+
+// POST /resolve-report closes every open report on a piece of content.
+// "resolve" keeps the content hidden (or deletes it with delete_content) and tells the author,
+// "dismiss" puts the content back up.
+func (h *SocialHandler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	adminID, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		ReportID      string `json:"report_id"`
+		Action        string `json:"action"` // "resolve" or "dismiss"
+		DeleteContent bool   `json:"delete_content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	if input.Action != "resolve" && input.Action != "dismiss" {
+		http.Error(w, `{"error": "action must be resolve or dismiss"}`, http.StatusBadRequest)
+		return
+	}
+	reportID, err := primitive.ObjectIDFromHex(input.ReportID)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid report ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	reportsCol := h.DB.Collection("Reports")
+	var report models.Report
+	if err := reportsCol.FindOne(context.Background(), bson.M{"_id": reportID, "status": "open"}).Decode(&report); err != nil {
+		http.Error(w, `{"error": "Open report not found"}`, http.StatusNotFound)
+		return
+	}
+
+	status := "dismissed"
+	if input.Action == "resolve" {
+		status = "resolved"
+	}
+
+	switch {
+	case input.Action == "dismiss":
+		err = helpers.SetContentHidden(h.DB, contentCollections[report.ContentType], report.ContentID, false)
+	case input.DeleteContent:
+		switch report.ContentType {
+		case "review":
+			err = helpers.DeleteReviewCascade(h.DB, report.ContentID)
+		case "quote":
+			err = helpers.DeleteQuoteCascade(h.DB, report.ContentID)
+		default:
+			err = helpers.DeleteCommentCascade(h.DB, contentCollections[report.ContentType], report.ContentID)
+		}
+	default:
+		err = helpers.SetContentHidden(h.DB, contentCollections[report.ContentType], report.ContentID, true)
+	}
+	if err != nil {
+		log.Printf("failed to %s report %s: %v", input.Action, reportID.Hex(), err)
+		http.Error(w, `{"error": "Failed to update reported content"}`, http.StatusInternalServerError)
+		return
+	}
+
+	closing := bson.M{"content_id": report.ContentID, "status": "open"}
+	if input.DeleteContent && input.Action == "resolve" {
+		// the delete cascade already closed them; record who did it
+		closing = bson.M{"content_id": report.ContentID, "$or": bson.A{
+			bson.M{"status": "open"},
+			bson.M{"status": "resolved", "resolved_by": bson.M{"$exists": false}},
+		}}
+	}
+	_, err = reportsCol.UpdateMany(context.Background(), closing, bson.M{"$set": bson.M{
+		"status":      status,
+		"resolved_by": adminID,
+		"resolved_at": time.Now(),
+	}})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to close reports"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if input.Action == "resolve" {
+		notifType := "content_removed"
+		target := report.ContentID
+		if input.DeleteContent {
+			// the content is gone, so point the notification at the report instead
+			target = report.ID
+		}
+		go helpers.CreateNotification(h.DB, report.AuthorID, adminID, target, notifType)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Report " + status})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GET /me/score-history?page=1&limit=20 explains the caller's rank score event by event
+func (h *SocialHandler) ScoreHistory(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	page, limit := int64(1), int64(20)
+	if p, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	eventsCol := h.DB.Collection("ScoreEvents")
+	filter := bson.M{"user_id": userID}
+	total, err := eventsCol.CountDocuments(context.Background(), filter)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to count score events"}`, http.StatusInternalServerError)
+		return
+	}
+
+	cursor, err := eventsCol.Find(context.Background(), filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch score history"}`, http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	events := []models.ScoreEvent{}
+	if err := cursor.All(context.Background(), &events); err != nil {
+		http.Error(w, `{"error": "Failed to parse score history"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// ===== Totals per reason over the whole history =====
+	byReason := map[string]int{}
+	reasonCursor, err := eventsCol.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": "$reason", "total": bson.M{"$sum": "$delta"}}}},
+	})
+	if err == nil {
+		for reasonCursor.Next(context.Background()) {
+			var row struct {
+				Reason string `bson:"_id"`
+				Total  int    `bson:"total"`
+			}
+			if err := reasonCursor.Decode(&row); err == nil {
+				byReason[row.Reason] = row.Total
+			}
+		}
+		reasonCursor.Close(context.Background())
+	}
+
+	var user models.User
+	_ = h.DB.Collection("users").FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"rank_score": user.RankScore,
+		"by_reason":  byReason,
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"events":     events,
+	})
+}
+
+// POST /recompute-scores lets the admin rebuild every rank_score from the ledger
+func (h *SocialHandler) RecomputeScores(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	result, err := helpers.RecomputeAllScores(h.DB)
+	if err != nil {
+		log.Printf("score recompute failed: %v", err)
+		http.Error(w, `{"error": "Failed to recompute scores"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": "Scores recomputed",
+		"result":  result,
+	})
+}
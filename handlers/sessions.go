@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"reading-tracker/backend/helpers"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// POST /token/refresh swaps a refresh token for a new access and refresh token
+func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if ok, err := helpers.AllowRequest(h.DB, "refresh:ip:"+clientIP(r), 60, 15*time.Minute); err != nil || !ok {
+		http.Error(w, `{"error": "Too many requests, try again later"}`, http.StatusTooManyRequests)
+		return
+	}
+
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.RefreshToken == "" {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+
+	session, user, refreshToken, err := helpers.RotateSession(h.DB, input.RefreshToken, clientIP(r))
+	if err == helpers.ErrInvalidRefreshToken {
+		http.Error(w, `{"error": "Refresh token is invalid or expired, log in again"}`, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to refresh token"}`, http.StatusInternalServerError)
+		return
+	}
+	if user.MustChangePassword {
+		// an admin forced a reset after this session started
+		_ = helpers.RevokeSession(h.DB, user.ID, session.ID)
+		http.Error(w, `{"error": "Password change required, log in again"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// role changes are picked up here, the new token is issued from the user as they are now
+	token, err := issueAccessToken(user, session.ID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(helpers.AccessTokenTTL().Seconds()),
+	})
+}
+
+// POST /logout ends the caller's session; with {"all": true} every session of theirs
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		All bool `json:"all"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&input)
+
+	var err error
+	if input.All {
+		err = helpers.RevokeAllSessions(h.DB, userID)
+	} else if sessionID := sessionFromToken(r); !sessionID.IsZero() {
+		err = helpers.RevokeSession(h.DB, userID, sessionID)
+		if err == mongo.ErrNoDocuments {
+			err = nil
+		}
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to log out"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
+}
+
+// GET /me/sessions lists the devices the caller is logged in on
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := helpers.ActiveSessions(h.DB, userID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch sessions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"sessions": sessions,
+		"current":  sessionFromToken(r),
+	})
+}
+
+// DELETE /me/sessions/{id} logs the caller out on one device
+func (h *AuthHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	sessionID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid session id"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = helpers.RevokeSession(h.DB, userID, sessionID)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, `{"error": "Session not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to end session"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Session ended"})
+}
+
+// POST /revoke-sessions lets the admin end every session of a user (body user_id or reader_id),
+// e.g. when an account was compromised or its role changed
+func (h *AuthHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		UserID   string `json:"user_id"`
+		ReaderID string `json:"reader_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	user, err := h.findUser(input.UserID, input.ReaderID)
+	if err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if err := helpers.RevokeAllSessions(h.DB, user.ID); err != nil {
+		http.Error(w, `{"error": "Failed to revoke sessions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "All sessions of the user were ended"})
+}
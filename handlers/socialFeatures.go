@@ -9,10 +9,12 @@ import (
 	"os"
 	"reading-tracker/backend/helpers"
 	"reading-tracker/backend/models"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -21,7 +23,9 @@ import (
 )
 
 type SocialHandler struct {
-	DB *mongo.Database
+	DB     *mongo.Database
+	Events *helpers.EventBus
+	Jobs   *helpers.Scheduler
 }
 
 func (h *SocialHandler) PublicReviews(w http.ResponseWriter, r *http.Request) {
@@ -29,10 +33,10 @@ func (h *SocialHandler) PublicReviews(w http.ResponseWriter, r *http.Request) {
 	isbn := r.URL.Query().Get("isbn")
 
 	var bookID primitive.ObjectID
+	var book models.Book
 	if isbn != "" {
 		// Find book by ISBN
 		booksCol := h.DB.Collection("books")
-		var book models.Book
 		err := booksCol.FindOne(context.Background(), bson.M{"isbn": isbn}).Decode(&book)
 		if err != nil {
 			http.Error(w, `{"error": "Book not found"}`, http.StatusNotFound)
@@ -46,6 +50,7 @@ func (h *SocialHandler) PublicReviews(w http.ResponseWriter, r *http.Request) {
 		"posted":          true,
 		"book_deleted":    false,
 		"ai_check_status": "approved",
+		"hidden":          bson.M{"$ne": true},
 	}
 	if isbn != "" {
 		filter["book_id"] = bookID
@@ -65,6 +70,7 @@ func (h *SocialHandler) PublicReviews(w http.ResponseWriter, r *http.Request) {
 		ISBN       string    `json:"isbn"`
 		ReaderID   string    `json:"reader_id"`
 		ReviewText string    `json:"review_text"`
+		Rating     float64   `json:"rating,omitempty"`
 		Upvotes    int       `json:"upvotes"`
 		CreatedAt  time.Time `json:"created_at"`
 	}
@@ -80,13 +86,24 @@ func (h *SocialHandler) PublicReviews(w http.ResponseWriter, r *http.Request) {
 			ISBN:       isbn,
 			ReaderID:   rev.ReaderID,
 			ReviewText: rev.ReviewText,
+			Rating:     rev.Rating,
 			Upvotes:    rev.Upvotes,
 			CreatedAt:  rev.CreatedAt,
 		})
 	}
+
+	response := map[string]any{"reviews": results}
+	if isbn != "" {
+		response["rating"] = map[string]any{
+			"average":      book.RatingAverage,
+			"count":        book.RatingCount,
+			"distribution": book.RatingDistribution,
+		}
+	}
+
 	// Return JSON response
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]any{"reviews": results})
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *SocialHandler) ToggleUpvote(w http.ResponseWriter, r *http.Request) {
@@ -199,23 +216,17 @@ func (h *SocialHandler) ToggleUpvote(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error": "Failed to update review"}`, http.StatusInternalServerError)
 		return
 	}
-		// after updating upvotes in DB
-	if !alreadyLiked {
-		// notify review author
-		go helpers.CreateNotification(
-			h.DB,
-			review.UserID,   // recipient (the review's author)
-			userID,          // actor (the one upvoting)
-			review.ID,       // target (the review itself)
-			"upvote_review", // type
-		)
-	}
-	// ===== Update leaderboard score for review's author =====
-	if scoreDelta != 0 {
-		if err := helpers.UpdateRankScore(h.DB, review.UserID, scoreDelta); err != nil {
-			log.Printf("failed to update rank score for user %s: %v", review.UserID.Hex(), err)
-		}
-	}
+	// ===== Notify the author, update their score and badges =====
+	h.Events.Publish(helpers.DomainEvent{
+		Type:     helpers.EventReviewUpvoted,
+		UserID:   review.UserID,
+		ActorID:  userID,
+		TargetID: review.ID,
+		Undo:     alreadyLiked,
+		Score: []helpers.ScoreChange{{
+			UserID: review.UserID, Delta: scoreDelta, Reason: helpers.ScoreReasonReviewUpvote, SourceType: "review", SourceID: review.ID,
+		}},
+	})
 
 	// ===== Return updated review =====
 	err = reviewsCol.FindOne(context.Background(), bson.M{"_id": reviewID, "book_deleted": false}).Decode(&review)
@@ -227,9 +238,6 @@ func (h *SocialHandler) ToggleUpvote(w http.ResponseWriter, r *http.Request) {
 
 
 
-	// ===== Update badges/class tags =====
-	helpers.UpdateUserBadgesAndClassTag(review.UserID, h.DB)
-
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]any{
 		"message": message,
@@ -271,6 +279,7 @@ func (h *SocialHandler) PostCommentReview(w http.ResponseWriter, r *http.Request
 	// ===== Parse input =====
 	var input struct {
 		ReviewID string `json:"review_id"`
+		ParentID string `json:"parent_id"` // optional, the comment being replied to
 		Text     string `json:"text"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -288,45 +297,82 @@ func (h *SocialHandler) PostCommentReview(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// ===== Insert comment =====
+	// ===== Check the review exists =====
+	reviewsCol := h.DB.Collection("Reviews")
+	var review models.Review
+	if err := reviewsCol.FindOne(context.Background(), bson.M{"_id": reviewID}).Decode(&review); err != nil {
+		http.Error(w, `{"error": "Review not found"}`, http.StatusNotFound)
+		return
+	}
+
 	commentsCol := h.DB.Collection("ReviewComments")
 
+	// ===== Resolve the parent when this is a reply =====
+	var parent helpers.ReplyParent
+	depth := 0
+	if input.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(input.ParentID)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid parent ID"}`, http.StatusBadRequest)
+			return
+		}
+		parent, err = helpers.FindReplyParent(h.DB, "ReviewComments", "review_id", reviewID, parentID)
+		if err != nil {
+			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusBadRequest)
+			return
+		}
+		depth = parent.Depth + 1
+	}
+
+	// ===== Insert comment =====
 	newComment := bson.M{
 		"review_id":  reviewID,
 		"user_id":    userID,
 		"text":       input.Text,
 		"upvotes":    0,
 		"upvoted_by": []primitive.ObjectID{},
+		"depth":      depth,
 		"created_at": time.Now(),
 	}
+	if depth > 0 {
+		newComment["parent_id"] = parent.ID
+	}
 
-	_, err = commentsCol.InsertOne(context.Background(), newComment)
+	res, err := commentsCol.InsertOne(context.Background(), newComment)
 	if err != nil {
 		http.Error(w, `{"error": "Failed to post comment"}`, http.StatusInternalServerError)
 		return
 	}
+	commentID := res.InsertedID.(primitive.ObjectID)
 
 	// after saving the comment
 	go helpers.CreateNotification(
 		h.DB,
-		userID,     // recipient = review owner
+		review.UserID,     // recipient = review owner
 		userID,            // actor = commenter
 		reviewID,         // target = review
 		"comment_review",  // type
 	)
-
+	if depth > 0 && parent.UserID != review.UserID {
+		go helpers.CreateNotification(h.DB, parent.UserID, userID, commentID, "reply")
+	}
+	go helpers.NotifyMentions(h.DB, userID, commentID, input.Text, review.UserID, parent.UserID)
 
 	// ===== Award points to the review author =====
-	reviewsCol := h.DB.Collection("Reviews")
-	var review models.Review
-	if err := reviewsCol.FindOne(context.Background(), bson.M{"_id": reviewID}).Decode(&review); err == nil {
-		_ = helpers.UpdateRankScore(h.DB, review.UserID, 1) // 1 point to review author
-		_ = helpers.UpdateUserBadgesAndClassTag(review.UserID, h.DB)
-	}
+	h.Events.Publish(helpers.DomainEvent{
+		Type:     helpers.EventReviewCommented,
+		UserID:   review.UserID,
+		ActorID:  userID,
+		TargetID: commentID,
+		Score: []helpers.ScoreChange{{
+			UserID: review.UserID, Delta: helpers.CommentPoints, Reason: helpers.ScoreReasonReviewComment, SourceType: "review_comment", SourceID: commentID,
+		}},
+	})
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Comment posted successfully",
+		"message":    "Comment posted successfully",
+		"comment_id": commentID,
 	})
 }
 
@@ -419,20 +465,17 @@ func (h *SocialHandler) ToggleCommentUpvoteReview(w http.ResponseWriter, r *http
 	}
 	
 
-	// ===== Update comment author's rank score =====
-	if scoreDelta != 0 {
-		_ = helpers.UpdateRankScore(h.DB, comment.UserID, scoreDelta)
-		_ = helpers.UpdateUserBadgesAndClassTag(comment.UserID, h.DB)
-	}
-	if !alreadyLiked {
-    go helpers.CreateNotification(
-        h.DB,
-        comment.UserID,
-        userID,
-        comment.ID,
-        "upvote_comment",
-    )
-}
+	// ===== Notify the comment author, update their score and badges =====
+	h.Events.Publish(helpers.DomainEvent{
+		Type:     helpers.EventCommentUpvoted,
+		UserID:   comment.UserID,
+		ActorID:  userID,
+		TargetID: comment.ID,
+		Undo:     alreadyLiked,
+		Score: []helpers.ScoreChange{{
+			UserID: comment.UserID, Delta: scoreDelta, Reason: helpers.ScoreReasonCommentUpvote, SourceType: "review_comment", SourceID: comment.ID,
+		}},
+	})
 
 
 	w.WriteHeader(http.StatusOK)
@@ -442,36 +485,148 @@ func (h *SocialHandler) ToggleCommentUpvoteReview(w http.ResponseWriter, r *http
 }
 
 
-// GET /leaderboard
+// GET /leaderboard?period=weekly|monthly|semester|all-time&limit=10
+// optional scopes insa_batch, dorm_number and educational_status narrow who is ranked,
+// group_by=dorm|batch|status ranks the teams instead of the readers
 func (h *SocialHandler) Leaderboard(w http.ResponseWriter, r *http.Request) {
 	usersCol := h.DB.Collection("users")
+	query := r.URL.Query()
 
 	// Optional: limit number of users returned via query param ?limit=10
-	limit := int64(10) // default top 10
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if parsed, err := strconv.ParseInt(l, 10, 64); err == nil && parsed > 0 {
+	limit := 10 // default top 10
+	if l := query.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
 			limit = parsed
 		}
 	}
 
-	// Query users, sort by rank_score descending
-	cursor, err := usersCol.Find(
-		context.Background(),
-		bson.M{}, // fetch all users
-		&options.FindOptions{
-			Sort:  bson.M{"rank_score": -1},
-			Limit: &limit,
-		},
-	)
+	period := query.Get("period")
+	if period == "" {
+		period = "all-time"
+	}
+	since, err := helpers.PeriodStart(period, time.Now())
+	if err != nil {
+		http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	// ===== Who is on this board =====
+	filter := bson.M{"role": bson.M{"$ne": "admin"}} // exclude admins from leaderboard
+	for param, field := range map[string]string{
+		"insa_batch":         "insa_batch",
+		"dorm_number":        "dorm_number",
+		"educational_status": "educational_status",
+	} {
+		if v := query.Get(param); v != "" {
+			filter[field] = v
+		}
+	}
+
+	cursor, err := usersCol.Find(context.Background(), filter)
 	if err != nil {
 		http.Error(w, `{"error": "Failed to fetch leaderboard"}`, http.StatusInternalServerError)
 		return
 	}
 	defer cursor.Close(context.Background())
 
+	var users []models.User
+	if err := cursor.All(context.Background(), &users); err != nil {
+		http.Error(w, `{"error": "Failed to fetch leaderboard"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// ===== Scores for the period =====
+	var periodScores map[primitive.ObjectID]int
+	if !since.IsZero() {
+		if periodScores, err = helpers.PeriodScores(h.DB, since); err != nil {
+			http.Error(w, `{"error": "Failed to compute scores"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	scoreOf := func(u models.User) int {
+		if since.IsZero() {
+			return u.RankScore
+		}
+		return periodScores[u.ID]
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		si, sj := scoreOf(users[i]), scoreOf(users[j])
+		if si != sj {
+			return si > sj
+		}
+		return users[i].Name < users[j].Name
+	})
+
+	response := map[string]any{
+		"period": period,
+	}
+	if !since.IsZero() {
+		response["since"] = since
+	}
+
+	// ===== Team rankings =====
+	if groupBy := query.Get("group_by"); groupBy != "" {
+		teamOf := map[string]func(models.User) string{
+			"dorm":   func(u models.User) string { return u.DormNumber },
+			"batch":  func(u models.User) string { return u.InsaBatch },
+			"status": func(u models.User) string { return u.EducationalStatus },
+		}[groupBy]
+		if teamOf == nil {
+			http.Error(w, `{"error": "group_by must be dorm, batch or status"}`, http.StatusBadRequest)
+			return
+		}
+
+		type Team struct {
+			Rank         int     `json:"rank"`
+			Team         string  `json:"team"`
+			TotalScore   int     `json:"total_score"`
+			Members      int     `json:"members"`
+			AverageScore float64 `json:"average_score"`
+		}
+		teams := make(map[string]*Team)
+		for _, u := range users {
+			name := teamOf(u)
+			if name == "" {
+				continue
+			}
+			if teams[name] == nil {
+				teams[name] = &Team{Team: name}
+			}
+			teams[name].TotalScore += scoreOf(u)
+			teams[name].Members++
+		}
+		ranked := []Team{}
+		for _, t := range teams {
+			t.AverageScore = float64(t.TotalScore) / float64(t.Members)
+			ranked = append(ranked, *t)
+		}
+		sort.Slice(ranked, func(i, j int) bool {
+			if ranked[i].TotalScore != ranked[j].TotalScore {
+				return ranked[i].TotalScore > ranked[j].TotalScore
+			}
+			return ranked[i].Team < ranked[j].Team
+		})
+		for i := range ranked {
+			ranked[i].Rank = i + 1
+			if i > 0 && ranked[i].TotalScore == ranked[i-1].TotalScore {
+				ranked[i].Rank = ranked[i-1].Rank
+			}
+		}
+
+		response["group_by"] = groupBy
+		response["teams"] = ranked
+		response["count"] = len(ranked)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	type LeaderboardUser struct {
+		Rank      int      `json:"rank"`
 		Name      string   `json:"name"`
 		ReaderID  string   `json:"reader_id"`
+		Score     int      `json:"score"` // points earned in the period
 		RankScore int      `json:"rank_score"`
 		BooksRead int      `json:"books_read"`
 		ClassTag  string   `json:"class_tag"`
@@ -498,33 +653,43 @@ func (h *SocialHandler) Leaderboard(w http.ResponseWriter, r *http.Request) {
 		}
 		userBadges[badge.UserID] = append(userBadges[badge.UserID], badge.Name)
 	}
-	// Build leaderboard
-	var leaderboard []LeaderboardUser
-	for cursor.Next(context.Background()) {
-		var user models.User
-		if err := cursor.Decode(&user); err != nil {
-			continue
-		}
-		if user.Role == "admin" { // exclude admins from leaderboard
-			continue
-		}
 
-		leaderboard = append(leaderboard, LeaderboardUser{
+	// Build leaderboard, readers on the same score share a rank
+	requesterID, _, hasRequester := requesterFromToken(r)
+	leaderboard := []LeaderboardUser{}
+	var you *LeaderboardUser
+	rank := 0
+	for i, user := range users {
+		if i == 0 || scoreOf(user) != scoreOf(users[i-1]) {
+			rank = i + 1
+		}
+		entry := LeaderboardUser{
+			Rank:      rank,
 			Name:      user.Name,
 			ReaderID:  user.ReaderID,
+			Score:     scoreOf(user),
 			RankScore: user.RankScore,
 			BooksRead: user.BooksRead,
 			ClassTag:  user.ClassTag,
-			Badges:     userBadges[user.ID], // attach badges
-		})
+			Badges:    userBadges[user.ID], // attach badges
+		}
+		if i < limit {
+			leaderboard = append(leaderboard, entry)
+		}
+		if hasRequester && user.ID == requesterID {
+			you = &entry
+		}
 	}
 
 	// Return leaderboard
+	response["leaderboard"] = leaderboard
+	response["count"] = len(leaderboard)
+	response["total_ranked"] = len(users)
+	if you != nil {
+		response["you"] = you
+	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]any{
-		"leaderboard": leaderboard,
-		"count":       len(leaderboard),
-	})
+	json.NewEncoder(w).Encode(response)
 }
 
 
@@ -708,6 +873,22 @@ func (h *SocialHandler) GetRecommendations(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// ===== The user's own ratings, so disliked books don't drive suggestions =====
+	userRatings := make(map[primitive.ObjectID]float64)
+	ratedCursor, err := h.DB.Collection("Reviews").Find(context.Background(), bson.M{
+		"user_id": userID,
+		"rating":  bson.M{"$gt": 0},
+	})
+	if err == nil {
+		var rated []models.Review
+		if err := ratedCursor.All(context.Background(), &rated); err == nil {
+			for _, rev := range rated {
+				userRatings[rev.BookID] = rev.Rating
+			}
+		}
+		ratedCursor.Close(context.Background())
+	}
+
 	// ===== Collect genres & authors =====
 	booksCol := h.DB.Collection("books")
 	var genres []string
@@ -718,25 +899,30 @@ func (h *SocialHandler) GetRecommendations(w http.ResponseWriter, r *http.Reques
 		var book models.Book
 		err := booksCol.FindOne(context.Background(), bson.M{"_id": prog.BookID}).Decode(&book)
 		if err == nil {
+			readBookIDs = append(readBookIDs, book.ID)
+			if rating, ok := userRatings[book.ID]; ok && rating < 3 {
+				continue
+			}
 			if book.Genre != "" {
 				genres = append(genres, book.Genre)
 			}
 			if book.Author != "" {
 				authors = append(authors, book.Author)
 			}
-			readBookIDs = append(readBookIDs, book.ID)
 		}
 	}
 
 	recommendations := []models.Book{}
 	seen := make(map[primitive.ObjectID]bool)
+	// best rated books first within each signal
+	byRating := bson.D{{Key: "rating_average", Value: -1}, {Key: "rating_count", Value: -1}}
 
 	// ===== 1. Recommend by Genre =====
 	if len(genres) > 0 {
 		cursor, err := booksCol.Find(context.Background(), bson.M{
 			"_id":   bson.M{"$nin": readBookIDs},
 			"genre": bson.M{"$in": genres},
-		}, options.Find().SetLimit(8))
+		}, options.Find().SetSort(byRating).SetLimit(8))
 		if err == nil {
 			var genreBooks []models.Book
 			if err := cursor.All(context.Background(), &genreBooks); err == nil {
@@ -757,7 +943,7 @@ func (h *SocialHandler) GetRecommendations(w http.ResponseWriter, r *http.Reques
 		cursor, err := booksCol.Find(context.Background(), bson.M{
 			"_id":    bson.M{"$nin": readBookIDs},
 			"author": bson.M{"$in": authors},
-		}, options.Find().SetLimit(limit))
+		}, options.Find().SetSort(byRating).SetLimit(limit))
 		if err == nil {
 			var authorBooks []models.Book
 			if err := cursor.All(context.Background(), &authorBooks); err == nil {
@@ -845,7 +1031,10 @@ func (h *SocialHandler) AddQuote(w http.ResponseWriter, r *http.Request) {
 
     // ===== Parse request body =====
     type Input struct {
-        Text string `json:"text"`
+        Text    string `json:"text"`
+        ISBN    string `json:"isbn"`    // optional, the book the quote comes from
+        Page    int    `json:"page"`    // optional
+        Chapter string `json:"chapter"` // optional
     }
     var input Input
     if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -857,15 +1046,45 @@ func (h *SocialHandler) AddQuote(w http.ResponseWriter, r *http.Request) {
         http.Error(w, `{"error": "Quote text cannot be empty"}`, http.StatusBadRequest)
         return
     }
+    if input.Page < 0 {
+        http.Error(w, `{"error": "Page cannot be negative"}`, http.StatusBadRequest)
+        return
+    }
+
+    quote := models.Quote{
+        UserID:    userID,
+        Text:      input.Text,
+        Upvotes:   0,
+        UpvotedBy: []primitive.ObjectID{},
+        CreatedAt: time.Now(),
+    }
+
+    // ===== Link the book, which must be on the student's reading list =====
+    if input.ISBN != "" {
+        var book models.Book
+        if err := h.DB.Collection("books").FindOne(context.Background(), bson.M{"isbn": input.ISBN}).Decode(&book); err != nil {
+            http.Error(w, `{"error": "Book not found"}`, http.StatusNotFound)
+            return
+        }
+        if count, _ := h.DB.Collection("reading").CountDocuments(context.Background(), bson.M{
+            "user_id": userID,
+            "book_id": book.ID,
+        }); count == 0 {
+            http.Error(w, `{"error": "you were not reading this book"}`, http.StatusForbidden)
+            return
+        }
+        if book.TotalPages > 0 && input.Page > book.TotalPages {
+            http.Error(w, `{"error": "Page is beyond the end of the book"}`, http.StatusBadRequest)
+            return
+        }
+        quote.BookID = book.ID
+        quote.ISBN = book.ISBN
+        quote.Page = input.Page
+        quote.Chapter = input.Chapter
+    }
 
     // ===== Insert into Quotes collection =====
     quotesCol := h.DB.Collection("Quotes")
-    quote := bson.M{
-        "user_id":    userID,
-        "text":       input.Text,
-        "upvotes":    0,
-        "created_at": time.Now(),
-    }
 
     res, err := quotesCol.InsertOne(context.Background(), quote)
     if err != nil {
@@ -873,10 +1092,15 @@ func (h *SocialHandler) AddQuote(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // ===== Update user badges & rank score =====
-    if err := helpers.UpdateUserBadgesAndClassTag(userID, h.DB); err != nil {
-        log.Printf("failed to update badges for user %s: %v", userID.Hex(), err)
-    }
+    go helpers.RecordActivity(h.DB, userID, "new_quote", quote.BookID, res.InsertedID.(primitive.ObjectID), "")
+
+    // ===== Update user badges =====
+    h.Events.Publish(helpers.DomainEvent{
+        Type:     helpers.EventQuoteAdded,
+        UserID:   userID,
+        ActorID:  userID,
+        TargetID: res.InsertedID.(primitive.ObjectID),
+    })
 
     // ===== Respond with the new quote ID =====
     w.WriteHeader(http.StatusCreated)
@@ -982,11 +1206,17 @@ func (h *SocialHandler) ToggleUpvoteQuote(w http.ResponseWriter, r *http.Request
         return
     }
 
-    // ===== Update author rank score =====
-    if scoreDelta != 0 {
-        _ = helpers.UpdateRankScore(h.DB, quote.UserID, scoreDelta)
-        helpers.UpdateUserBadgesAndClassTag(quote.UserID, h.DB)
-    }
+    // ===== Notify the author, update their score and badges =====
+    h.Events.Publish(helpers.DomainEvent{
+        Type:     helpers.EventQuoteUpvoted,
+        UserID:   quote.UserID,
+        ActorID:  userID,
+        TargetID: quote.ID,
+        Undo:     alreadyLiked,
+        Score: []helpers.ScoreChange{{
+            UserID: quote.UserID, Delta: scoreDelta, Reason: helpers.ScoreReasonQuoteUpvote, SourceType: "quote", SourceID: quote.ID,
+        }},
+    })
 
     // ===== Return updated quote info =====
     err = quotesCol.FindOne(context.Background(), bson.M{"_id": quoteID}).Decode(&quote)
@@ -994,15 +1224,6 @@ func (h *SocialHandler) ToggleUpvoteQuote(w http.ResponseWriter, r *http.Request
         http.Error(w, `{"error": "Failed to fetch updated quote"}`, http.StatusInternalServerError)
         return
     }
-	if !alreadyLiked {
-    go helpers.CreateNotification(
-        h.DB,
-        quote.UserID,
-        userID,
-        quote.ID,
-        "upvote_quote",
-    )
-}
 
 
     w.WriteHeader(http.StatusOK)
@@ -1046,8 +1267,9 @@ func (h *SocialHandler) AddCommentQuote(w http.ResponseWriter, r *http.Request)
 
 	// ===== Parse input from body =====
 	var input struct {
-		QuoteID string `json:"quote_id"`
-		Text    string `json:"text"`
+		QuoteID  string `json:"quote_id"`
+		ParentID string `json:"parent_id"` // optional, the comment being replied to
+		Text     string `json:"text"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
@@ -1077,24 +1299,53 @@ func (h *SocialHandler) AddCommentQuote(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Resolve the parent when this is a reply
+	var parent helpers.ReplyParent
+	depth := 0
+	if input.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(input.ParentID)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid parent ID"}`, http.StatusBadRequest)
+			return
+		}
+		parent, err = helpers.FindReplyParent(h.DB, "QuoteComments", "quote_id", quoteID, parentID)
+		if err != nil {
+			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusBadRequest)
+			return
+		}
+		depth = parent.Depth + 1
+	}
+
 	// Insert the comment
 	comment := bson.M{
 		"quote_id":   quoteID,
 		"user_id":    userID,
 		"text":       input.Text,
+		"upvotes":    0,
+		"upvoted_by": []primitive.ObjectID{},
+		"depth":      depth,
 		"created_at": time.Now(),
 	}
-	_, err = commentsCol.InsertOne(context.Background(), comment)
+	if depth > 0 {
+		comment["parent_id"] = parent.ID
+	}
+	res, err := commentsCol.InsertOne(context.Background(), comment)
 	if err != nil {
 		http.Error(w, `{"error": "Failed to add comment"}`, http.StatusInternalServerError)
 		return
 	}
+	commentID := res.InsertedID.(primitive.ObjectID)
 
-	// Update rank score for comment author (0.5 points)
-	_ = helpers.UpdateRankScore(h.DB, userID, 1) // if using integer, you can scale 0.5*2 = 1
-
-	// Optionally: Update badges for the comment author
-	_ = helpers.UpdateUserBadgesAndClassTag(userID, h.DB)
+	// Update rank score and badges for comment author (0.5 points)
+	h.Events.Publish(helpers.DomainEvent{
+		Type:     helpers.EventQuoteCommented,
+		UserID:   userID,
+		ActorID:  userID,
+		TargetID: commentID,
+		Score: []helpers.ScoreChange{{
+			UserID: userID, Delta: helpers.CommentPoints, Reason: helpers.ScoreReasonQuoteComment, SourceType: "quote_comment", SourceID: commentID, // if using integer, you can scale 0.5*2 = 1
+		}},
+	})
 	// after saving comment
 		go helpers.CreateNotification(
 			h.DB,
@@ -1103,11 +1354,15 @@ func (h *SocialHandler) AddCommentQuote(w http.ResponseWriter, r *http.Request)
 			quote.ID,
 			"comment_quote",
 		)
-
+	if depth > 0 && parent.UserID != quote.UserID {
+		go helpers.CreateNotification(h.DB, parent.UserID, userID, commentID, "reply")
+	}
+	go helpers.NotifyMentions(h.DB, userID, commentID, input.Text, quote.UserID, parent.UserID)
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Comment added successfully",
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":    "Comment added successfully",
+		"comment_id": commentID,
 	})
 }
 
@@ -1214,9 +1469,17 @@ func (h *SocialHandler) ToggleCommentUpvoteQuote(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// Update rank score of comment author
-	_ = helpers.UpdateRankScore(h.DB, comment.UserID, scoreDelta)
-	_ = helpers.UpdateUserBadgesAndClassTag(comment.UserID, h.DB)
+	// Notify the comment author, update their score and badges
+	h.Events.Publish(helpers.DomainEvent{
+		Type:     helpers.EventCommentUpvoted,
+		UserID:   comment.UserID,
+		ActorID:  userID,
+		TargetID: comment.ID,
+		Undo:     alreadyLiked,
+		Score: []helpers.ScoreChange{{
+			UserID: comment.UserID, Delta: scoreDelta, Reason: helpers.ScoreReasonCommentUpvote, SourceType: "quote_comment", SourceID: comment.ID,
+		}},
+	})
 
 	// Fetch updated comment
 	err = commentsCol.FindOne(context.Background(), bson.M{"_id": commentID}).Decode(&comment)
@@ -1225,15 +1488,6 @@ func (h *SocialHandler) ToggleCommentUpvoteQuote(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if !alreadyLiked{
-    go helpers.CreateNotification(
-        h.DB,
-        comment.UserID,
-        userID,
-        comment.ID,
-        "upvote_comment",
-    )
-}
 
 
 	w.WriteHeader(http.StatusOK)
@@ -1267,14 +1521,23 @@ func (h *SocialHandler) ListNotifications(w http.ResponseWriter, r *http.Request
 	claims, _ := token.Claims.(jwt.MapClaims)
 	userID, _ := primitive.ObjectIDFromHex(claims["user_id"].(string))
 
+	// ?page=1&limit=20, and ?group=false to list alike notifications one by one
+	page, limit := 1, 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	group := r.URL.Query().Get("group") != "false"
+
 	notificationsCol := h.DB.Collection("Notifications")
 
+	// groups are built from the latest 1000 notifications
 	cursor, err := notificationsCol.Find(
 		context.Background(),
 		bson.M{"user_id": userID},
-		&options.FindOptions{
-			Sort: bson.M{"created_at": -1}, // latest first
-		},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(1000), // latest first
 	)
 	if err != nil {
 		http.Error(w, `{"error": "Failed to fetch notifications"}`, http.StatusInternalServerError)
@@ -1288,10 +1551,17 @@ func (h *SocialHandler) ListNotifications(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	unseen, _ := notificationsCol.CountDocuments(context.Background(), bson.M{"user_id": userID, "seen": false})
+	rendered, total := helpers.RenderNotifications(h.DB, notifications, group, page, limit)
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]any{
-		"count":         len(notifications),
-		"notifications": notifications,
+		"page":          page,
+		"limit":         limit,
+		"total":         total,
+		"unseen":        unseen,
+		"count":         len(rendered),
+		"notifications": rendered,
 	})
 }
 
@@ -1348,6 +1618,7 @@ func (h *SocialHandler) SearchReviews(w http.ResponseWriter, r *http.Request) {
         "posted":      true,
         "ai_check_status": "approved",
         "book_deleted": false,
+        "hidden":       bson.M{"$ne": true},
     }
 
     // Optional filters
@@ -1421,11 +1692,13 @@ func (h *SocialHandler) SearchQuotes(w http.ResponseWriter, r *http.Request) {
     // ===== Parse query params =====
     query := r.URL.Query().Get("query")
     userIDStr := r.URL.Query().Get("user_id")
+    isbn := r.URL.Query().Get("isbn")
 
   
 
     filter := bson.M{
-        "text": bson.M{"$regex": query, "$options": "i"},
+        "text":   bson.M{"$regex": query, "$options": "i"},
+        "hidden": bson.M{"$ne": true},
     }
 
     if userIDStr != "" {
@@ -1434,6 +1707,9 @@ func (h *SocialHandler) SearchQuotes(w http.ResponseWriter, r *http.Request) {
             filter["user_id"] = userID
         }
     }
+    if isbn != "" {
+        filter["isbn"] = isbn
+    }
 
     quotesCol := h.DB.Collection("Quotes")
     cursor, err := quotesCol.Find(context.Background(), filter)
@@ -1448,12 +1724,17 @@ func (h *SocialHandler) SearchQuotes(w http.ResponseWriter, r *http.Request) {
         Text      string             `json:"text"`
         UserName  string             `json:"user_name"`
         ReaderID  string             `json:"reader_id"`
+        BookTitle string             `json:"book_title,omitempty"`
+        ISBN      string             `json:"isbn,omitempty"`
+        Page      int                `json:"page,omitempty"`
+        Chapter   string             `json:"chapter,omitempty"`
         Upvotes   int                `json:"upvotes"`
         CreatedAt time.Time          `json:"created_at"`
     }
 
     var results []QuoteWithUser
     usersCol := h.DB.Collection("users")
+    booksCol := h.DB.Collection("books")
 
     for cursor.Next(context.Background()) {
         var q models.Quote
@@ -1463,13 +1744,23 @@ func (h *SocialHandler) SearchQuotes(w http.ResponseWriter, r *http.Request) {
 
         // Fetch user info
         var user models.User
-        _ = usersCol.FindOne(context.Background(), bson.M{"_id": q.AuthorID}).Decode(&user)
+        _ = usersCol.FindOne(context.Background(), bson.M{"_id": q.UserID}).Decode(&user)
+
+        // Fetch book title for quotes linked to a book
+        var book models.Book
+        if !q.BookID.IsZero() {
+            _ = booksCol.FindOne(context.Background(), bson.M{"_id": q.BookID}).Decode(&book)
+        }
 
         results = append(results, QuoteWithUser{
             ID:        q.ID,
             Text:      q.Text,
             UserName:  user.Name,
             ReaderID:  user.ReaderID,
+            BookTitle: book.Title,
+            ISBN:      q.ISBN,
+            Page:      q.Page,
+            Chapter:   q.Chapter,
             Upvotes:   q.Upvotes,
             CreatedAt: q.CreatedAt,
         })
@@ -2168,4 +2459,65 @@ func (h *SocialHandler) Analytics(w http.ResponseWriter, r *http.Request) {
 		sendError(w, http.StatusInternalServerError, "Failed to encode response")
 		return
 	}
-}
\ No newline at end of file
+}
+// GET /books/{isbn}/quotes lists the quotes taken from one book, most upvoted first
+func (h *SocialHandler) BookQuotes(w http.ResponseWriter, r *http.Request) {
+	isbn := mux.Vars(r)["isbn"]
+
+	var book models.Book
+	if err := h.DB.Collection("books").FindOne(context.Background(), bson.M{"isbn": isbn}).Decode(&book); err != nil {
+		http.Error(w, `{"error": "Book not found"}`, http.StatusNotFound)
+		return
+	}
+
+	cursor, err := h.DB.Collection("Quotes").Find(context.Background(),
+		bson.M{"book_id": book.ID, "hidden": bson.M{"$ne": true}},
+		options.Find().SetSort(bson.D{{Key: "upvotes", Value: -1}, {Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch quotes"}`, http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	type BookQuote struct {
+		ID        primitive.ObjectID `json:"id"`
+		Text      string             `json:"text"`
+		UserName  string             `json:"user_name"`
+		ReaderID  string             `json:"reader_id"`
+		Page      int                `json:"page,omitempty"`
+		Chapter   string             `json:"chapter,omitempty"`
+		Upvotes   int                `json:"upvotes"`
+		CreatedAt time.Time          `json:"created_at"`
+	}
+
+	quotes := []BookQuote{}
+	usersCol := h.DB.Collection("users")
+	for cursor.Next(context.Background()) {
+		var q models.Quote
+		if err := cursor.Decode(&q); err != nil {
+			continue
+		}
+		var user models.User
+		_ = usersCol.FindOne(context.Background(), bson.M{"_id": q.UserID}).Decode(&user)
+
+		quotes = append(quotes, BookQuote{
+			ID:        q.ID,
+			Text:      q.Text,
+			UserName:  user.Name,
+			ReaderID:  user.ReaderID,
+			Page:      q.Page,
+			Chapter:   q.Chapter,
+			Upvotes:   q.Upvotes,
+			CreatedAt: q.CreatedAt,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"isbn":   book.ISBN,
+		"title":  book.Title,
+		"count":  len(quotes),
+		"quotes": quotes,
+	})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// scope of the token a user gets while they still have to change their password
+const changePasswordScope = "change_password"
+
+// issueAccessToken signs the short lived JWT of a session. Users who must change their
+// password get a token without a session that only works for /change-password
+func issueAccessToken(user models.User, sessionID primitive.ObjectID) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": user.ID.Hex(),
+		"role":    user.Role,
+		"tv":      user.TokenVersion,
+		"exp":     time.Now().Add(helpers.AccessTokenTTL()).Unix(),
+	}
+	if user.MustChangePassword {
+		claims["scope"] = changePasswordScope
+		claims["exp"] = time.Now().Add(15 * time.Minute).Unix()
+	} else {
+		claims["sid"] = sessionID.Hex()
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// startSession logs a user in on the requesting device and returns the tokens for the response
+func (h *AuthHandler) startSession(r *http.Request, user models.User, device string) (map[string]any, error) {
+	if user.MustChangePassword {
+		token, err := issueAccessToken(user, primitive.NilObjectID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"token": token, "must_change_password": true}, nil
+	}
+
+	session, refreshToken, err := helpers.CreateSession(h.DB, user, device, r.UserAgent(), clientIP(r))
+	if err != nil {
+		return nil, err
+	}
+	token, err := issueAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"token":                token,
+		"refresh_token":        refreshToken,
+		"expires_in":           int(helpers.AccessTokenTTL().Seconds()),
+		"must_change_password": false,
+	}, nil
+}
+
+// parseToken reads the JWT from the Authorization header (or ?token= for event streams)
+func parseToken(r *http.Request) (jwt.MapClaims, bool) {
+	tokenString := r.Header.Get("Authorization")
+	if tokenString == "" {
+		tokenString = r.URL.Query().Get("token")
+	}
+	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
+		tokenString = tokenString[7:]
+	}
+	if tokenString == "" {
+		return nil, false
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, ok
+}
+
+// sessionFromToken is the session id in the caller's access token
+func sessionFromToken(r *http.Request) primitive.ObjectID {
+	claims, ok := parseToken(r)
+	if !ok {
+		return primitive.NilObjectID
+	}
+	sid, _ := claims["sid"].(string)
+	id, _ := primitive.ObjectIDFromHex(sid)
+	return id
+}
+
+// TokenGuard sits in front of the router because most handlers parse the token
+// themselves. It turns away tokens of revoked sessions or an old token version, and
+// restricted tokens everywhere except /change-password
+func TokenGuard(db *mongo.Database) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := parseToken(r)
+			if !ok {
+				// no token, or one the handler will reject anyway
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			idStr, _ := claims["user_id"].(string)
+			userID, _ := primitive.ObjectIDFromHex(idStr)
+			version, hasVersion := claims["tv"].(float64)
+			restricted := claims["scope"] == changePasswordScope
+			sid, _ := claims["sid"].(string)
+			sessionID, _ := primitive.ObjectIDFromHex(sid)
+
+			if !hasVersion || (!restricted && sessionID.IsZero()) || !helpers.SessionActive(db, userID, sessionID, int(version)) {
+				w.Header().Set("Content-Type", "application/json")
+				http.Error(w, `{"error": "Session expired or revoked, log in again"}`, http.StatusUnauthorized)
+				return
+			}
+			if restricted && r.URL.Path != "/change-password" {
+				w.Header().Set("Content-Type", "application/json")
+				http.Error(w, `{"error": "Password change required", "must_change_password": true}`, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"reading-tracker/backend/helpers"
+	"reading-tracker/backend/models"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// POST /webhooks lets the admin register an endpoint for some event types,
+// body {"url": "...", "events": ["BookBorrowed"], "secret": "optional"}.
+// The secret is only shown in this response
+func (h *SocialHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	adminID, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"error": "Invalid input"}`, http.StatusBadRequest)
+		return
+	}
+	if u, err := url.Parse(input.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, `{"error": "url must be an http(s) url"}`, http.StatusBadRequest)
+		return
+	}
+	if len(input.Events) == 0 {
+		http.Error(w, `{"error": "events is required"}`, http.StatusBadRequest)
+		return
+	}
+	known := make(map[string]bool)
+	for _, t := range helpers.WebhookEventTypes {
+		known[t] = true
+	}
+	for _, t := range input.Events {
+		if !known[t] {
+			http.Error(w, `{"error": "Unknown event type"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			http.Error(w, `{"error": "Failed to create secret"}`, http.StatusInternalServerError)
+			return
+		}
+		secret = hex.EncodeToString(b)
+	}
+
+	hook := models.Webhook{
+		URL:       input.URL,
+		Secret:    secret,
+		Events:    input.Events,
+		Active:    true,
+		CreatedBy: adminID,
+		CreatedAt: time.Now(),
+	}
+	res, err := h.DB.Collection("Webhooks").InsertOne(context.Background(), hook)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to save webhook"}`, http.StatusInternalServerError)
+		return
+	}
+	hook.ID = res.InsertedID.(primitive.ObjectID)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"webhook": hook,
+		"secret":  secret,
+	})
+}
+
+// GET /webhooks lists the registered webhooks and the event types they can subscribe to
+func (h *SocialHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	cursor, err := h.DB.Collection("Webhooks").Find(context.Background(), bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch webhooks"}`, http.StatusInternalServerError)
+		return
+	}
+	hooks := []models.Webhook{}
+	if err := cursor.All(context.Background(), &hooks); err != nil {
+		http.Error(w, `{"error": "Failed to decode webhooks"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"webhooks":    hooks,
+		"event_types": helpers.WebhookEventTypes,
+	})
+}
+
+// DELETE /webhooks/{id} removes a webhook; its pending deliveries end up dead
+func (h *SocialHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	hookID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid webhook id"}`, http.StatusBadRequest)
+		return
+	}
+	res, err := h.DB.Collection("Webhooks").DeleteOne(context.Background(), bson.M{"_id": hookID})
+	if err != nil {
+		http.Error(w, `{"error": "Failed to delete webhook"}`, http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.Error(w, `{"error": "Webhook not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook deleted"})
+}
+
+// GET /webhooks/{id}/deliveries?status=dead&page=1&limit=20 shows the delivery log of a webhook
+func (h *SocialHandler) WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	hookID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid webhook id"}`, http.StatusBadRequest)
+		return
+	}
+	page, limit := int64(1), int64(20)
+	if p, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	filter := bson.M{"webhook_id": hookID}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter["status"] = status
+	}
+	deliveriesCol := h.DB.Collection("WebhookDeliveries")
+	total, err := deliveriesCol.CountDocuments(context.Background(), filter)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to count deliveries"}`, http.StatusInternalServerError)
+		return
+	}
+	cursor, err := deliveriesCol.Find(context.Background(), filter, options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch deliveries"}`, http.StatusInternalServerError)
+		return
+	}
+	deliveries := []models.WebhookDelivery{}
+	if err := cursor.All(context.Background(), &deliveries); err != nil {
+		http.Error(w, `{"error": "Failed to decode deliveries"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"deliveries": deliveries,
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+	})
+}
+
+// POST /webhook-deliveries/{id}/retry sends a dead delivery again, e.g. after the receiver was fixed
+func (h *SocialHandler) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := requesterFromToken(r)
+	if !ok {
+		http.Error(w, `{"error": "Invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	if role != "admin" {
+		http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		return
+	}
+
+	deliveryID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid delivery id"}`, http.StatusBadRequest)
+		return
+	}
+	res, err := h.DB.Collection("WebhookDeliveries").UpdateOne(context.Background(),
+		bson.M{"_id": deliveryID, "status": "dead"},
+		bson.M{"$set": bson.M{"status": "pending", "attempts": 0, "next_attempt_at": time.Now()}},
+	)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to retry delivery"}`, http.StatusInternalServerError)
+		return
+	}
+	if res.MatchedCount == 0 {
+		http.Error(w, `{"error": "Dead delivery not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Delivery queued again"})
+}
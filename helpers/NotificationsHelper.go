@@ -4,12 +4,31 @@ import (
 	"context"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"reading-tracker/backend/models"
 )
 
+// Notification delivery modes a user can pick per type
+const (
+	NotifyInstant = "instant"
+	NotifyDigest  = "digest"
+	NotifyOff     = "off"
+)
 
+// NotificationMode is how a user wants notifications of a type delivered (instant unless they chose otherwise)
+func NotificationMode(db *mongo.Database, userID primitive.ObjectID, notifType string) string {
+	var prefs models.NotificationPreferences
+	err := db.Collection("NotificationPreferences").FindOne(context.Background(), bson.M{"user_id": userID}).Decode(&prefs)
+	if err != nil {
+		return NotifyInstant
+	}
+	if mode, ok := prefs.Modes[notifType]; ok {
+		return mode
+	}
+	return NotifyInstant
+}
 
 // CreateNotification inserts a new notification
 func CreateNotification(db *mongo.Database, userID, actorID, targetID primitive.ObjectID, notifType string) error {
@@ -18,17 +37,41 @@ func CreateNotification(db *mongo.Database, userID, actorID, targetID primitive.
 		return nil
 	}
 
-	notificationsCol := db.Collection("Notifications")
+	switch NotificationMode(db, userID, notifType) {
+	case NotifyOff:
+		return nil
+	case NotifyDigest:
+		// held back until the next digest is built
+		_, err := db.Collection("NotificationDigestQueue").InsertOne(context.Background(), models.DigestItem{
+			UserID:    userID,
+			ActorID:   actorID,
+			Type:      notifType,
+			TargetID:  targetID,
+			CreatedAt: time.Now(),
+		})
+		return err
+	}
 
-	Notification := models.Notification{
+	return insertNotification(db, models.Notification{
 		UserID:    userID,
 		ActorID:   actorID,
 		Type:      notifType,
 		TargetID:  targetID,
 		Seen:      false,
 		CreatedAt: time.Now(),
+	})
+}
+
+func insertNotification(db *mongo.Database, Notification models.Notification) error {
+	notificationsCol := db.Collection("Notifications")
+
+	res, err := notificationsCol.InsertOne(context.Background(), Notification)
+	if err != nil {
+		return err
 	}
 
-	_, err := notificationsCol.InsertOne(context.Background(), Notification)
-	return err
+	// push it to the recipient's open notification streams
+	Notification.ID = res.InsertedID.(primitive.ObjectID)
+	Notifications.publishLocal(Notification)
+	return nil
 }
@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"reading-tracker/backend/models"
+)
+
+// progressMilestones are the percentages of a book that show up in the feed
+var progressMilestones = []int{25, 50, 75, 100}
+
+// RecordActivity stores an event for the followers' feed
+func RecordActivity(db *mongo.Database, userID primitive.ObjectID, activityType string, bookID, targetID primitive.ObjectID, detail string) error {
+	_, err := db.Collection("Activities").InsertOne(context.Background(), models.Activity{
+		UserID:    userID,
+		Type:      activityType,
+		BookID:    bookID,
+		TargetID:  targetID,
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+// CrossedMilestone returns the highest milestone passed when progress moves from
+// oldPages to newPages, or 0 if none was crossed
+func CrossedMilestone(oldPages, newPages, totalPages int) int {
+	if totalPages <= 0 || newPages <= oldPages {
+		return 0
+	}
+	crossed := 0
+	for _, m := range progressMilestones {
+		threshold := totalPages * m / 100
+		if oldPages < threshold && newPages >= threshold {
+			crossed = m
+		}
+	}
+	return crossed
+}
@@ -0,0 +1,281 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+// BadgeCounters are the counters a badge rule can use, with what they count
+var BadgeCounters = map[string]string{
+	"books_read":         "books finished (approved reviews)",
+	"best_streak":        "longest reading streak in days on any book",
+	"reading_today":      "1 when reading progress was logged in the last 24 hours",
+	"reviews":            "reviews posted",
+	"upvoted_reviews":    "reviews with at least one upvote",
+	"top_review_upvotes": "upvotes on your most upvoted review",
+	"quotes":             "quotes shared",
+	"top_quote_upvotes":  "upvotes on your most upvoted quote",
+	"upvotes_received":   "upvotes received on reviews, quotes and comments",
+}
+
+// defaultBadgeDefinitions seed the catalog the first time it is read
+var defaultBadgeDefinitions = []models.BadgeDefinition{
+	{Name: "Page Turner", Description: "Finish 3 books", Icon: "📖", Score: 2, Rule: []models.BadgeCondition{{Counter: "books_read", Min: 3}}},
+	{Name: "Book Worm", Description: "Finish 5 books", Icon: "🐛", Score: 3, Rule: []models.BadgeCondition{{Counter: "books_read", Min: 5}}},
+	{Name: "Marathon Reader", Description: "Finish 8 books", Icon: "🏃", Score: 5, Rule: []models.BadgeCondition{{Counter: "books_read", Min: 8}}},
+	{Name: "Streak Keeper", Description: "Keep a 7 day reading streak", Icon: "🔥", Score: 4, Rule: []models.BadgeCondition{{Counter: "best_streak", Min: 7}}},
+	{Name: "Upvoted Author", Description: "Get 5 upvotes on one review", Icon: "👍", Score: 3, Rule: []models.BadgeCondition{{Counter: "top_review_upvotes", Min: 5}}},
+	{Name: "Community Helper", Description: "Have 3 reviews that others upvoted", Icon: "🤝", Score: 3, Rule: []models.BadgeCondition{{Counter: "upvoted_reviews", Min: 3}}},
+	{Name: "Daily Reader", Description: "Log reading progress today", Icon: "📅", Score: 2, Rule: []models.BadgeCondition{{Counter: "reading_today", Min: 1}}},
+	{Name: "Quote Contributor", Description: "Share your first quote", Icon: "💬", Score: 3, Rule: []models.BadgeCondition{{Counter: "quotes", Min: 1}}},
+	{Name: "Popular Quote", Description: "Get 10 upvotes on one quote", Icon: "⭐", Score: 5, Rule: []models.BadgeCondition{{Counter: "top_quote_upvotes", Min: 10}}},
+}
+
+// ValidateBadgeDefinition checks a definition an admin sends before it is saved
+func ValidateBadgeDefinition(def models.BadgeDefinition) error {
+	if def.Name == "" {
+		return errors.New("name is required")
+	}
+	if def.Score < 0 {
+		return errors.New("score cannot be negative")
+	}
+	if len(def.Rule) == 0 {
+		return errors.New("rule needs at least one condition")
+	}
+	for _, c := range def.Rule {
+		if _, ok := BadgeCounters[c.Counter]; !ok {
+			return fmt.Errorf("unknown counter %q", c.Counter)
+		}
+		if c.Min <= 0 {
+			return fmt.Errorf("min for %s must be positive", c.Counter)
+		}
+	}
+	return nil
+}
+
+// BadgeCatalog returns the badge definitions, seeding the defaults into an empty
+// catalog. With activeOnly, retired badges are left out
+func BadgeCatalog(db *mongo.Database, activeOnly bool) ([]models.BadgeDefinition, error) {
+	col := db.Collection("BadgeDefinitions")
+	count, err := col.CountDocuments(context.Background(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		for _, def := range defaultBadgeDefinitions {
+			def.Active = true
+			def.UpdatedAt = time.Now()
+			// upsert by name so two first readers don't seed twice
+			_, err := col.UpdateOne(context.Background(),
+				bson.M{"name": def.Name},
+				bson.M{"$setOnInsert": def},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	filter := bson.M{}
+	if activeOnly {
+		filter["active"] = true
+	}
+	cursor, err := col.Find(context.Background(), filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defs := []models.BadgeDefinition{}
+	err = cursor.All(context.Background(), &defs)
+	return defs, err
+}
+
+// upvoteStats sums and maxes the upvotes on a user's documents in a collection
+func upvoteStats(db *mongo.Database, collection string, filter bson.M) (total, top, upvoted, count int, err error) {
+	cursor, err := db.Collection(collection).Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     nil,
+			"total":   bson.M{"$sum": "$upvotes"},
+			"top":     bson.M{"$max": "$upvotes"},
+			"upvoted": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$upvotes", 0}}, 1, 0}}},
+			"count":   bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+	if cursor.Next(context.Background()) {
+		var row struct {
+			Total   int `bson:"total"`
+			Top     int `bson:"top"`
+			Upvoted int `bson:"upvoted"`
+			Count   int `bson:"count"`
+		}
+		if err = cursor.Decode(&row); err != nil {
+			return
+		}
+		total, top, upvoted, count = row.Total, row.Top, row.Upvoted, row.Count
+	}
+	err = cursor.Err()
+	return
+}
+
+// UserBadgeCounters works out every counter in BadgeCounters for a user
+func UserBadgeCounters(db *mongo.Database, user models.User) (map[string]int, error) {
+	counters := map[string]int{"books_read": user.BooksRead}
+
+	reviewUpvotes, topReview, upvotedReviews, reviews, err := upvoteStats(db, "Reviews", bson.M{"user_id": user.ID, "posted": true})
+	if err != nil {
+		return nil, err
+	}
+	quoteUpvotes, topQuote, _, quotes, err := upvoteStats(db, "Quotes", bson.M{"user_id": user.ID})
+	if err != nil {
+		return nil, err
+	}
+	commentUpvotes := 0
+	for _, collection := range []string{"ReviewComments", "QuoteComments"} {
+		total, _, _, _, err := upvoteStats(db, collection, bson.M{"user_id": user.ID})
+		if err != nil {
+			return nil, err
+		}
+		commentUpvotes += total
+	}
+	counters["reviews"] = reviews
+	counters["upvoted_reviews"] = upvotedReviews
+	counters["top_review_upvotes"] = topReview
+	counters["quotes"] = quotes
+	counters["top_quote_upvotes"] = topQuote
+	counters["upvotes_received"] = reviewUpvotes + quoteUpvotes + commentUpvotes
+
+	progressCol := db.Collection("ReadingProgress")
+	var best struct {
+		StreakDays int `bson:"streak_days"`
+	}
+	err = progressCol.FindOne(context.Background(), bson.M{"user_id": user.ID},
+		options.FindOne().SetSort(bson.M{"streak_days": -1})).Decode(&best)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	counters["best_streak"] = best.StreakDays
+
+	today, err := progressCol.CountDocuments(context.Background(), bson.M{
+		"user_id":      user.ID,
+		"last_updated": bson.M{"$gte": time.Now().Add(-24 * time.Hour)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if today > 0 {
+		counters["reading_today"] = 1
+	}
+	return counters, nil
+}
+
+// BadgeEarned reports whether the counters satisfy every condition of a rule
+func BadgeEarned(def models.BadgeDefinition, counters map[string]int) bool {
+	for _, c := range def.Rule {
+		if counters[c.Counter] < c.Min {
+			return false
+		}
+	}
+	return true
+}
+
+// ConditionProgress is how far a user is towards one condition
+type ConditionProgress struct {
+	Counter string `json:"counter"`
+	Current int    `json:"current"`
+	Target  int    `json:"target"`
+}
+
+// BadgeProgress is how far a user is towards an unearned badge
+type BadgeProgress struct {
+	Badge      models.BadgeDefinition `json:"badge"`
+	Percent    int                    `json:"percent"`
+	Conditions []ConditionProgress    `json:"conditions"`
+}
+
+// ProgressTowards averages the user's progress over the conditions of a rule
+func ProgressTowards(def models.BadgeDefinition, counters map[string]int) BadgeProgress {
+	progress := BadgeProgress{Badge: def, Conditions: []ConditionProgress{}}
+	if len(def.Rule) == 0 {
+		return progress
+	}
+	sum := 0.0
+	for _, c := range def.Rule {
+		current := counters[c.Counter]
+		progress.Conditions = append(progress.Conditions, ConditionProgress{Counter: c.Counter, Current: current, Target: c.Min})
+		if current >= c.Min {
+			sum += 1
+		} else {
+			sum += float64(current) / float64(c.Min)
+		}
+	}
+	progress.Percent = int(sum / float64(len(def.Rule)) * 100)
+	return progress
+}
+
+// EnsureBadgeIndexes makes a badge unique per user and name, so concurrent workers
+// can't both award it. Duplicates left from before are removed first, taking back
+// the points they added; it returns how many were removed
+func EnsureBadgeIndexes(db *mongo.Database) (int, error) {
+	badgesCol := db.Collection("Badges")
+	cursor, err := badgesCol.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"user_id": "$user_id", "name": "$name"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var groups []struct {
+		Key struct {
+			UserID primitive.ObjectID `bson:"user_id"`
+			Name   string             `bson:"name"`
+		} `bson:"_id"`
+		IDs []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(context.Background(), &groups); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, g := range groups {
+		// the first one awarded is kept
+		sort.Slice(g.IDs, func(i, j int) bool { return g.IDs[i].Hex() < g.IDs[j].Hex() })
+		for _, id := range g.IDs[1:] {
+			score, err := BadgeScore(db, id, g.Key.Name)
+			if err != nil {
+				return removed, err
+			}
+			if _, err := badgesCol.DeleteOne(context.Background(), bson.M{"_id": id}); err != nil {
+				return removed, err
+			}
+			if err := UpdateRankScore(db, g.Key.UserID, -score, ScoreReasonBadgeRevoked, "badge", id); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+
+	_, err = badgesCol.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return removed, err
+}
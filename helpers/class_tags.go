@@ -0,0 +1,276 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+// ClassTagTier is one class tag and what a reader needs to hold it; a reader gets
+// the highest tier whose every minimum they meet
+type ClassTagTier struct {
+	Name           string `json:"name"`
+	MinTenureDays  int    `json:"min_tenure_days"`
+	MinBooksRead   int    `json:"min_books_read"`
+	MinActiveWeeks int    `json:"min_active_weeks"`
+	MinScore       int    `json:"min_score"`
+}
+
+// defaultClassTagTiers are used unless CLASS_TAG_TIERS holds a JSON list of tiers
+var defaultClassTagTiers = []ClassTagTier{
+	{Name: "Beginner"},
+	{Name: "Casual", MinTenureDays: 30, MinBooksRead: 1, MinActiveWeeks: 2, MinScore: 10},
+	{Name: "Regular", MinTenureDays: 90, MinBooksRead: 3, MinActiveWeeks: 6, MinScore: 40},
+	{Name: "Dedicated", MinTenureDays: 300, MinBooksRead: 6, MinActiveWeeks: 16, MinScore: 100},
+	{Name: "Family", MinTenureDays: 365, MinBooksRead: 10, MinActiveWeeks: 26, MinScore: 200},
+}
+
+// activeWeekReasons are the score events that show the reader themselves did something
+var activeWeekReasons = []string{ScoreReasonReadingStarted, ScoreReasonReadingStreak, ScoreReasonReviewApproved, ScoreReasonQuoteComment}
+
+// ClassTagTiers returns the configured tiers, lowest first
+func ClassTagTiers() []ClassTagTier {
+	raw := os.Getenv("CLASS_TAG_TIERS")
+	if raw == "" {
+		return defaultClassTagTiers
+	}
+	var tiers []ClassTagTier
+	if err := json.Unmarshal([]byte(raw), &tiers); err != nil || len(tiers) == 0 {
+		log.Printf("invalid CLASS_TAG_TIERS, using the default tiers: %v", err)
+		return defaultClassTagTiers
+	}
+	return tiers
+}
+
+// DefaultClassTag is the tier every new reader starts in
+func DefaultClassTag() string {
+	return ClassTagTiers()[0].Name
+}
+
+// MigrateStarterBadges moves the starter badges ApproveUser used to write to the
+// lowercase "badges" collection, keyed by the pending registration's id, into Badges
+// under the reader's user id. The registration is deleted on approval, so rows whose
+// owner can't be found any more are dropped. It returns how many rows were moved
+func MigrateStarterBadges(db *mongo.Database) (int, error) {
+	legacyCol := db.Collection("badges")
+	cursor, err := legacyCol.Find(context.Background(), bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	var legacy []models.Badge
+	if err := cursor.All(context.Background(), &legacy); err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, b := range legacy {
+		userID, err := legacyBadgeOwner(db, b.UserID)
+		if err != nil {
+			return moved, err
+		}
+		if !userID.IsZero() {
+			// upsert, so a badge the reader already holds in Badges is kept as it is
+			_, err = db.Collection("Badges").UpdateOne(context.Background(),
+				bson.M{"user_id": userID, "name": DefaultClassTag()},
+				bson.M{"$setOnInsert": bson.M{
+					"description": b.Description,
+					"type":        b.Type,
+					"created_at":  b.CreatedAt,
+				}},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return moved, err
+			}
+			moved++
+		}
+		if _, err := legacyCol.DeleteOne(context.Background(), bson.M{"_id": b.ID}); err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
+// legacyBadgeOwner finds the user behind a legacy starter badge: the id itself, or the
+// email of a pending registration that still exists. It is zero when neither is found
+func legacyBadgeOwner(db *mongo.Database, id primitive.ObjectID) (primitive.ObjectID, error) {
+	usersCol := db.Collection("users")
+	idOnly := options.FindOne().SetProjection(bson.M{"_id": 1})
+	var user struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err := usersCol.FindOne(context.Background(), bson.M{"_id": id}, idOnly).Decode(&user)
+	if err != mongo.ErrNoDocuments {
+		return user.ID, err
+	}
+
+	var pending models.PendingRegistration
+	err = db.Collection("pending_registrations").FindOne(context.Background(), bson.M{"_id": id}).Decode(&pending)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, nil
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	err = usersCol.FindOne(context.Background(), bson.M{"email": pending.Email}, idOnly).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, nil
+	}
+	return user.ID, err
+}
+
+// tierIndex finds a tier by name, ignoring case since older tags were stored lowercase
+func tierIndex(tiers []ClassTagTier, name string) int {
+	for i, t := range tiers {
+		if strings.EqualFold(t.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ActiveWeeks counts the distinct weeks in which a reader did something on the platform
+func ActiveWeeks(db *mongo.Database, userID primitive.ObjectID) (int, error) {
+	weekStage := bson.D{{Key: "$group", Value: bson.M{"_id": bson.M{
+		"year": bson.M{"$isoWeekYear": "$created_at"},
+		"week": bson.M{"$isoWeek": "$created_at"},
+	}}}}
+
+	weeks := make(map[[2]int]bool)
+	for collection, match := range map[string]bson.M{
+		"Activities":  {"user_id": userID},
+		"ScoreEvents": {"user_id": userID, "reason": bson.M{"$in": activeWeekReasons}},
+	} {
+		cursor, err := db.Collection(collection).Aggregate(context.Background(), mongo.Pipeline{
+			{{Key: "$match", Value: match}},
+			weekStage,
+		})
+		if err != nil {
+			return 0, err
+		}
+		for cursor.Next(context.Background()) {
+			var row struct {
+				ID struct {
+					Year int `bson:"year"`
+					Week int `bson:"week"`
+				} `bson:"_id"`
+			}
+			if err := cursor.Decode(&row); err == nil {
+				weeks[[2]int{row.ID.Year, row.ID.Week}] = true
+			}
+		}
+		cursor.Close(context.Background())
+	}
+	return len(weeks), nil
+}
+
+// DetermineClassTag works out the tier a reader has reached
+func DetermineClassTag(db *mongo.Database, user models.User) (string, error) {
+	activeWeeks, err := ActiveWeeks(db, user.ID)
+	if err != nil {
+		return "", err
+	}
+	tenureDays := int(time.Since(user.CreatedAt).Hours() / 24)
+
+	tiers := ClassTagTiers()
+	tag := tiers[0].Name
+	for _, t := range tiers {
+		if tenureDays >= t.MinTenureDays && user.BooksRead >= t.MinBooksRead &&
+			activeWeeks >= t.MinActiveWeeks && user.RankScore >= t.MinScore {
+			tag = t.Name
+		}
+	}
+	return tag, nil
+}
+
+// ApplyClassTag re-evaluates a reader's class tag. A change is kept in the tier
+// history; a promotion also earns the tier's badge and a notification
+func ApplyClassTag(db *mongo.Database, user models.User) (bool, error) {
+	classTag, err := DetermineClassTag(db, user)
+	if err != nil {
+		return false, err
+	}
+	if strings.EqualFold(classTag, user.ClassTag) {
+		if classTag != user.ClassTag {
+			// same tier under its old lowercase name, just store the current spelling
+			_, err = db.Collection("users").UpdateOne(context.Background(),
+				bson.M{"_id": user.ID},
+				bson.M{"$set": bson.M{"class_tag": classTag}},
+			)
+		}
+		return false, err
+	}
+
+	if _, err := db.Collection("users").UpdateOne(context.Background(),
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"class_tag": classTag}},
+	); err != nil {
+		return false, err
+	}
+
+	tiers := ClassTagTiers()
+	promoted := tierIndex(tiers, classTag) > tierIndex(tiers, user.ClassTag)
+	_, _ = db.Collection("ClassTagHistory").InsertOne(context.Background(), models.ClassTagChange{
+		UserID:    user.ID,
+		From:      user.ClassTag,
+		To:        classTag,
+		Promoted:  promoted,
+		CreatedAt: time.Now(),
+	})
+
+	// update badge for class-tag type
+	badgesCol := db.Collection("Badges")
+	count, _ := badgesCol.CountDocuments(context.Background(), bson.M{"user_id": user.ID, "name": classTag})
+	if count == 0 {
+		_, _ = badgesCol.InsertOne(context.Background(), models.Badge{
+			UserID:      user.ID,
+			Name:        classTag,
+			Type:        "class-tag",
+			Description: "Earned the " + classTag + " badge!",
+			CreatedAt:   time.Now(),
+		})
+	}
+
+	if promoted {
+		_ = CreateNotification(db, user.ID, primitive.NilObjectID, user.ID, "class_tag_promoted")
+		_ = RecordActivity(db, user.ID, "class_tag_promoted", primitive.NilObjectID, primitive.NilObjectID, classTag)
+	}
+	return true, nil
+}
+
+// ReevaluateAllClassTags runs ApplyClassTag for every reader and returns how many changed
+func ReevaluateAllClassTags(db *mongo.Database) (int, error) {
+	cursor, err := db.Collection("users").Find(context.Background(), bson.M{"role": bson.M{"$ne": "admin"}},
+		options.Find().SetProjection(bson.M{"password": 0}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	changed := 0
+	for cursor.Next(context.Background()) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			continue
+		}
+		ok, err := ApplyClassTag(db, user)
+		if err != nil {
+			log.Printf("class tag for %s: %v", user.ID.Hex(), err)
+			continue
+		}
+		if ok {
+			changed++
+		}
+	}
+	return changed, cursor.Err()
+}
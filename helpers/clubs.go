@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"reading-tracker/backend/models"
+)
+
+// ClubScheduleStatus is where a member stands against one club's schedule
+type ClubScheduleStatus struct {
+	ClubID             primitive.ObjectID     `json:"club_id"`
+	ClubName           string                 `json:"club_name"`
+	PagesRead          int                    `json:"pages_read"`
+	OnTrack            bool                   `json:"on_track"`
+	MissedMilestones   []models.ClubMilestone `json:"missed_milestones,omitempty"` // due already but not reached
+	NextMilestone      *models.ClubMilestone  `json:"next_milestone,omitempty"`
+	UnlockedMilestones int                    `json:"unlocked_milestones"`
+}
+
+// ScheduleStatus compares pages read with a club's milestones
+func ScheduleStatus(club models.Club, pagesRead int, now time.Time) ClubScheduleStatus {
+	status := ClubScheduleStatus{
+		ClubID:    club.ID,
+		ClubName:  club.Name,
+		PagesRead: pagesRead,
+		OnTrack:   true,
+	}
+	for i, m := range club.Schedule {
+		if pagesRead >= m.Page {
+			status.UnlockedMilestones++
+			continue
+		}
+		if now.After(m.DueDate) {
+			status.MissedMilestones = append(status.MissedMilestones, m)
+			status.OnTrack = false
+		} else if status.NextMilestone == nil {
+			status.NextMilestone = &club.Schedule[i]
+		}
+	}
+	return status
+}
+
+// ClubScheduleForReader checks a reader's progress on a book against every club
+// they belong to that is reading it
+func ClubScheduleForReader(db *mongo.Database, userID, bookID primitive.ObjectID, pagesRead int) ([]ClubScheduleStatus, error) {
+	cursor, err := db.Collection("Clubs").Find(context.Background(), bson.M{
+		"members": userID,
+		"book_id": bookID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var clubs []models.Club
+	if err := cursor.All(context.Background(), &clubs); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var statuses []ClubScheduleStatus
+	for _, club := range clubs {
+		statuses = append(statuses, ScheduleStatus(club, pagesRead, now))
+	}
+	return statuses, nil
+}
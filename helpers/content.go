@@ -0,0 +1,332 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Points handed out per upvote, comment and approval, kept here so deletes can take them back
+const (
+	ReviewUpvotePoints   = 2
+	QuoteUpvotePoints    = 2
+	CommentUpvotePoints  = 1
+	CommentPoints        = 1
+	ReviewApprovedPoints = 5
+	BookCompletedPoints  = 10
+)
+
+// RecordRevision stores the text a piece of content had before an edit
+func RecordRevision(db *mongo.Database, contentType string, contentID, editorID primitive.ObjectID, text string, rating float64) error {
+	_, err := db.Collection("Revisions").InsertOne(context.Background(), bson.M{
+		"content_type": contentType,
+		"content_id":   contentID,
+		"text":         text,
+		"rating":       rating,
+		"edited_by":    editorID,
+		"edited_at":    time.Now(),
+	})
+	return err
+}
+
+// commentSourceType names a comment collection in the score ledger
+func commentSourceType(collection string) string {
+	if collection == "ReviewComments" {
+		return "review_comment"
+	}
+	return "quote_comment"
+}
+
+// commentedContent is the part of a review/quote/comment a cascade needs
+type commentedContent struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	UserID  primitive.ObjectID `bson:"user_id"`
+	Upvotes int                `bson:"upvotes"`
+}
+
+// deleteComments removes every comment under a review or quote, reverses the
+// points their upvotes earned and returns what was deleted
+func deleteComments(db *mongo.Database, collection, parentField string, parentID primitive.ObjectID) ([]commentedContent, error) {
+	commentsCol := db.Collection(collection)
+	cursor, err := commentsCol.Find(context.Background(), bson.M{parentField: parentID})
+	if err != nil {
+		return nil, err
+	}
+	var comments []commentedContent
+	if err := cursor.All(context.Background(), &comments); err != nil {
+		return nil, err
+	}
+	if len(comments) == 0 {
+		return nil, nil
+	}
+
+	if _, err := commentsCol.DeleteMany(context.Background(), bson.M{parentField: parentID}); err != nil {
+		return nil, err
+	}
+	for _, c := range comments {
+		if c.Upvotes > 0 {
+			_ = UpdateRankScore(db, c.UserID, -c.Upvotes*CommentUpvotePoints, ScoreReasonContentDeleted, commentSourceType(collection), c.ID)
+		}
+	}
+	return comments, nil
+}
+
+func contentIDs(comments []commentedContent, extra primitive.ObjectID) []primitive.ObjectID {
+	ids := []primitive.ObjectID{extra}
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+// cleanupTargets drops notifications, feed activities and revisions that point at deleted
+// content, and closes its open reports so they leave the moderation queue
+func cleanupTargets(db *mongo.Database, ids []primitive.ObjectID) error {
+	if _, err := db.Collection("Reports").UpdateMany(context.Background(),
+		bson.M{"content_id": bson.M{"$in": ids}, "status": "open"},
+		bson.M{"$set": bson.M{"status": "resolved", "resolved_at": time.Now()}},
+	); err != nil {
+		return err
+	}
+	if _, err := db.Collection("Notifications").DeleteMany(context.Background(), bson.M{"target_id": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+	if _, err := db.Collection("Activities").DeleteMany(context.Background(), bson.M{"target_id": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+	_, err := db.Collection("Revisions").DeleteMany(context.Background(), bson.M{"content_id": bson.M{"$in": ids}})
+	return err
+}
+
+// SetContentHidden hides or restores a review, quote or comment along with its feed
+// entries. Only an approved review that is visible counts towards its book's rating,
+// so hiding one takes the rating out and restoring it puts it back
+func SetContentHidden(db *mongo.Database, collection string, contentID primitive.ObjectID, hidden bool) error {
+	var before struct {
+		BookID primitive.ObjectID `bson:"book_id"`
+		Rating float64            `bson:"rating"`
+		Posted bool               `bson:"posted"`
+	}
+	// only a change of state passes the filter, so the rating moves once
+	err := db.Collection(collection).FindOneAndUpdate(context.Background(),
+		bson.M{"_id": contentID, "hidden": bson.M{"$ne": hidden}},
+		bson.M{"$set": bson.M{"hidden": hidden}},
+	).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// followers' feeds leave it out while it is hidden
+	if _, err := db.Collection("Activities").UpdateMany(context.Background(),
+		bson.M{"target_id": contentID},
+		bson.M{"$set": bson.M{"hidden": hidden}},
+	); err != nil {
+		return err
+	}
+
+	if collection != "Reviews" || !before.Posted {
+		return nil
+	}
+	if hidden {
+		return RemoveBookRating(db, before.BookID, before.Rating)
+	}
+	return AddBookRating(db, before.BookID, before.Rating)
+}
+
+// MigrateApprovedReviews sets approved_before on reviews approved before the flag
+// existed: those approved now, and those sent back to moderation after an edit, which
+// the ledger shows were approved. Without it their next approval would credit the
+// author again
+func MigrateApprovedReviews(db *mongo.Database) (int64, error) {
+	reviewsCol := db.Collection("Reviews")
+	res, err := reviewsCol.UpdateMany(context.Background(),
+		bson.M{"approved_before": bson.M{"$exists": false}, "ai_check_status": "approved"},
+		bson.M{"$set": bson.M{"approved_before": true}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	updated := res.ModifiedCount
+
+	approved, err := db.Collection("ScoreEvents").Distinct(context.Background(), "source_id", bson.M{
+		"reason":      ScoreReasonReviewApproved,
+		"source_type": "review",
+	})
+	if err != nil {
+		return updated, err
+	}
+	if len(approved) > 0 {
+		res, err = reviewsCol.UpdateMany(context.Background(),
+			bson.M{"approved_before": bson.M{"$exists": false}, "_id": bson.M{"$in": approved}},
+			bson.M{"$set": bson.M{"approved_before": true}},
+		)
+		if err != nil {
+			return updated, err
+		}
+		updated += res.ModifiedCount
+	}
+	return updated, nil
+}
+
+// DeleteReviewCascade removes a review with its comments, notifications, revisions,
+// feed entries, the points it earned and its rating on the book. An approved review
+// also gives back its approval points and the finished book, so deleting and
+// resubmitting it earns nothing
+func DeleteReviewCascade(db *mongo.Database, reviewID primitive.ObjectID) error {
+	reviewsCol := db.Collection("Reviews")
+	var review struct {
+		commentedContent `bson:",inline"`
+		BookID           primitive.ObjectID `bson:"book_id"`
+		Rating           float64            `bson:"rating"`
+		Posted           bool               `bson:"posted"`
+		Hidden           bool               `bson:"hidden"`
+		ApprovedBefore   bool               `bson:"approved_before"`
+	}
+	if err := reviewsCol.FindOne(context.Background(), bson.M{"_id": reviewID}).Decode(&review); err != nil {
+		return err
+	}
+
+	comments, err := deleteComments(db, "ReviewComments", "review_id", reviewID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := reviewsCol.DeleteOne(context.Background(), bson.M{"_id": reviewID}); err != nil {
+		return err
+	}
+
+	// the review author got points for every upvote and comment
+	if delta := review.Upvotes*ReviewUpvotePoints + len(comments)*CommentPoints; delta > 0 {
+		_ = UpdateRankScore(db, review.UserID, -delta, ScoreReasonContentDeleted, "review", reviewID)
+	}
+	if review.Posted && !review.Hidden {
+		_ = RemoveBookRating(db, review.BookID, review.Rating)
+	}
+	if review.ApprovedBefore {
+		if err := reverseApproval(db, review.UserID, reviewID, review.BookID); err != nil {
+			return err
+		}
+	}
+
+	return cleanupTargets(db, contentIDs(comments, reviewID))
+}
+
+// reverseApproval takes back what the first approval of a review gave: its points,
+// the points for finishing the book and the books_read count. What the ledger holds
+// is taken back; reviews approved before the ledger fall back to the fixed points
+func reverseApproval(db *mongo.Database, userID, reviewID, bookID primitive.ObjectID) error {
+	approved, err := ledgerTotals(db, bson.M{"user_id": userID, "reason": ScoreReasonReviewApproved, "source_id": reviewID})
+	if err != nil {
+		return err
+	}
+	completed, err := ledgerTotals(db, bson.M{"user_id": userID, "reason": ScoreReasonBookCompleted, "source_id": bookID})
+	if err != nil {
+		return err
+	}
+	approvedPoints, approvedFound := approved[userID]
+	if !approvedFound {
+		approvedPoints = ReviewApprovedPoints
+	}
+	completedPoints, completedFound := completed[userID]
+	if !completedFound {
+		completedPoints = BookCompletedPoints
+	}
+
+	if approvedPoints > 0 {
+		if err := UpdateRankScore(db, userID, -approvedPoints, ScoreReasonContentDeleted, "review", reviewID); err != nil {
+			return err
+		}
+	}
+	// written as book_completed so the book's events add up to zero and a new
+	// approval can give them again
+	if completedPoints > 0 {
+		if err := UpdateRankScore(db, userID, -completedPoints, ScoreReasonBookCompleted, "book", bookID); err != nil {
+			return err
+		}
+	}
+	_, err = db.Collection("users").UpdateOne(context.Background(),
+		bson.M{"_id": userID, "books_read": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"books_read": -1}},
+	)
+	return err
+}
+
+// DeleteQuoteCascade removes a quote with its comments, notifications, revisions
+// and the points its upvotes earned
+func DeleteQuoteCascade(db *mongo.Database, quoteID primitive.ObjectID) error {
+	quotesCol := db.Collection("Quotes")
+	var quote commentedContent
+	if err := quotesCol.FindOne(context.Background(), bson.M{"_id": quoteID}).Decode(&quote); err != nil {
+		return err
+	}
+
+	comments, err := deleteComments(db, "QuoteComments", "quote_id", quoteID)
+	if err != nil {
+		return err
+	}
+	// quote commenters were given points for commenting
+	for _, c := range comments {
+		_ = UpdateRankScore(db, c.UserID, -CommentPoints, ScoreReasonContentDeleted, "quote_comment", c.ID)
+	}
+
+	if _, err := quotesCol.DeleteOne(context.Background(), bson.M{"_id": quoteID}); err != nil {
+		return err
+	}
+	if quote.Upvotes > 0 {
+		_ = UpdateRankScore(db, quote.UserID, -quote.Upvotes*QuoteUpvotePoints, ScoreReasonContentDeleted, "quote", quoteID)
+	}
+
+	return cleanupTargets(db, contentIDs(comments, quoteID))
+}
+
+// DeleteCommentCascade removes a single review or quote comment
+func DeleteCommentCascade(db *mongo.Database, collection string, commentID primitive.ObjectID) error {
+	commentsCol := db.Collection(collection)
+	var comment struct {
+		commentedContent `bson:",inline"`
+		ReviewID         primitive.ObjectID `bson:"review_id"`
+	}
+	if err := commentsCol.FindOne(context.Background(), bson.M{"_id": commentID}).Decode(&comment); err != nil {
+		return err
+	}
+
+	// replies go with the comment they answer
+	cursor, err := commentsCol.Find(context.Background(), bson.M{"parent_id": commentID})
+	if err != nil {
+		return err
+	}
+	var replies []commentedContent
+	if err := cursor.All(context.Background(), &replies); err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		if err := DeleteCommentCascade(db, collection, reply.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := commentsCol.DeleteOne(context.Background(), bson.M{"_id": commentID}); err != nil {
+		return err
+	}
+
+	if comment.Upvotes > 0 {
+		_ = UpdateRankScore(db, comment.UserID, -comment.Upvotes*CommentUpvotePoints, ScoreReasonContentDeleted, commentSourceType(collection), commentID)
+	}
+	// take back the comment point: review comments pay the review author, quote comments the commenter
+	if collection == "ReviewComments" {
+		var review commentedContent
+		if err := db.Collection("Reviews").FindOne(context.Background(), bson.M{"_id": comment.ReviewID}).Decode(&review); err == nil {
+			_ = UpdateRankScore(db, review.UserID, -CommentPoints, ScoreReasonContentDeleted, "review_comment", commentID)
+		}
+	} else {
+		_ = UpdateRankScore(db, comment.UserID, -CommentPoints, ScoreReasonContentDeleted, "quote_comment", commentID)
+	}
+
+	return cleanupTargets(db, []primitive.ObjectID{commentID})
+}
@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"* * * * *", false},
+		{"30 2 * * 1-5", false},
+		{"1,15 8-18/2 * 1-12 0-7", false},
+		{"*/10 * * * *", false},
+		{"5/15 * * * *", false},
+		{"@hourly", false},
+		{"@daily", false},
+		{"@midnight", false},
+		{"@weekly", false},
+		{"@monthly", false},
+		{"", true},
+		{"* * * *", true},
+		{"* * * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"*/0 * * * *", true},
+		{"5-1 * * * *", true},
+		{"a * * * *", true},
+		{"1-x * * * *", true},
+		{"@yearly", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParseCron(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCron(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+	// 2026-01-01 is a Thursday
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"every quarter hour", "*/15 * * * *", at(2026, 1, 1, 10, 7), at(2026, 1, 1, 10, 15)},
+		{"strictly after", "0 * * * *", at(2026, 1, 1, 10, 0), at(2026, 1, 1, 11, 0)},
+		{"seconds are dropped", "* * * * *", time.Date(2026, 1, 1, 10, 0, 59, 0, time.UTC), at(2026, 1, 1, 10, 1)},
+		{"next day", "30 2 * * *", at(2026, 1, 1, 3, 0), at(2026, 1, 2, 2, 30)},
+		{"weekdays skip the weekend", "0 9 * * 1-5", at(2026, 1, 2, 10, 0), at(2026, 1, 5, 9, 0)},
+		{"first of the month", "0 0 1 * *", at(2026, 1, 15, 0, 0), at(2026, 2, 1, 0, 0)},
+		{"weekly is sunday", "@weekly", at(2026, 1, 1, 0, 0), at(2026, 1, 4, 0, 0)},
+		{"7 is sunday", "0 0 * * 7", at(2026, 1, 1, 0, 0), at(2026, 1, 4, 0, 0)},
+		{"either day field", "0 0 13 * 5", at(2026, 1, 1, 0, 0), at(2026, 1, 2, 0, 0)},
+		{"range with step", "0 8-18/4 * * *", at(2026, 1, 1, 12, 1), at(2026, 1, 1, 16, 0)},
+		{"across the year", "0 0 1 1 *", at(2026, 6, 1, 0, 0), at(2027, 1, 1, 0, 0)},
+		{"leap day", "0 0 29 2 *", at(2026, 1, 1, 0, 0), at(2028, 2, 29, 0, 0)},
+		{"never", "0 0 30 2 *", at(2026, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.spec, err)
+			}
+			if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) for %q = %v, want %v", tt.from, tt.spec, got, tt.want)
+			}
+		})
+	}
+}
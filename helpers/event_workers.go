@@ -0,0 +1,51 @@
+package helpers
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// upvoteNotifications is the notification the content owner gets for each upvote event
+var upvoteNotifications = map[string]string{
+	EventReviewUpvoted:  "upvote_review",
+	EventQuoteUpvoted:   "upvote_quote",
+	EventCommentUpvoted: "upvote_comment",
+}
+
+// RegisterEventWorkers subscribes the score, badge, notification and webhook workers
+func RegisterEventWorkers(bus *EventBus) {
+	all := []string{
+		EventReviewApproved, EventReviewUpvoted, EventReviewCommented, EventCommentUpvoted,
+		EventQuoteAdded, EventQuoteUpvoted, EventQuoteCommented, EventProgressUpdated, EventBookBorrowed,
+	}
+	bus.Subscribe("score", scoreWorker, false, all...)
+	bus.Subscribe("badges", badgeWorker, true, all...)
+	bus.Subscribe("notifications", notificationWorker, false, EventReviewUpvoted, EventQuoteUpvoted, EventCommentUpvoted)
+	bus.Subscribe("webhooks", webhookWorker, false, WebhookEventTypes...)
+}
+
+// scoreWorker writes the score changes an event carries
+func scoreWorker(db *mongo.Database, e DomainEvent) error {
+	for _, c := range e.Score {
+		if err := RecordScoreEvent(db, c.ID, c.UserID, c.Delta, c.Reason, c.SourceType, c.SourceID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// badgeWorker re-checks the badges and class tag of the reader an event is about
+func badgeWorker(db *mongo.Database, e DomainEvent) error {
+	if e.UserID.IsZero() {
+		return nil
+	}
+	return UpdateUserBadgesAndClassTag(e.UserID, db)
+}
+
+// notificationWorker tells the owner about a new upvote on their content
+func notificationWorker(db *mongo.Database, e DomainEvent) error {
+	notifType, ok := upvoteNotifications[e.Type]
+	if !ok || e.Undo || e.UserID == e.ActorID {
+		return nil
+	}
+	return CreateNotification(db, e.UserID, e.ActorID, e.TargetID, notifType)
+}
@@ -0,0 +1,275 @@
+package helpers
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Domain event types published by the handlers
+const (
+	EventReviewApproved  = "ReviewApproved"
+	EventReviewUpvoted   = "ReviewUpvoted"
+	EventReviewCommented = "ReviewCommented"
+	EventCommentUpvoted  = "CommentUpvoted"
+	EventQuoteAdded      = "QuoteAdded"
+	EventQuoteUpvoted    = "QuoteUpvoted"
+	EventQuoteCommented  = "QuoteCommented"
+	EventProgressUpdated = "ProgressUpdated"
+	EventBookBorrowed    = "BookBorrowed"
+	EventBookReturned    = "BookReturned"
+	EventBadgeEarned     = "BadgeEarned"
+)
+
+// ScoreChange is a rank score change that comes with an event
+type ScoreChange struct {
+	ID         primitive.ObjectID // makes the change idempotent when a delivery is retried
+	UserID     primitive.ObjectID
+	Delta      int
+	Reason     string
+	SourceType string
+	SourceID   primitive.ObjectID
+}
+
+// DomainEvent is something that happened to a reader's content or reading
+type DomainEvent struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Type     string             `bson:"type"`
+	UserID   primitive.ObjectID `bson:"user_id"`   // the reader the event is about (owner of the content)
+	ActorID  primitive.ObjectID `bson:"actor_id"`  // who caused it, can be the same reader
+	TargetID primitive.ObjectID `bson:"target_id"` // the review, quote, comment, book or badge
+	Undo     bool               `bson:"undo"`      // an upvote was taken back
+	Score    []ScoreChange      `bson:"score"`
+	At       time.Time          `bson:"at"`
+}
+
+// EventWorker handles one event; returning an error gets the delivery retried
+type EventWorker func(db *mongo.Database, e DomainEvent) error
+
+type subscription struct {
+	name     string
+	types    map[string]bool
+	worker   EventWorker
+	coalesce bool // at most one pending delivery per user
+}
+
+// eventDelivery is one event waiting for one worker, kept in the EventDeliveries collection
+type eventDelivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	Worker        string             `bson:"worker"`
+	Event         DomainEvent        `bson:"event"`
+	Status        string             `bson:"status"` // pending or running
+	Attempts      int                `bson:"attempts"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at"`
+	LeaseUntil    time.Time          `bson:"lease_until"`
+	CoalesceKey   string             `bson:"coalesce_key,omitempty"`
+}
+
+// how long a worker may take on a delivery before another instance picks it up again
+const eventLease = 5 * time.Minute
+
+// EventBus hands published events to subscribed workers on background goroutines.
+// Deliveries are stored in MongoDB before Publish returns, so a restart only delays them
+type EventBus struct {
+	db          *mongo.Database
+	maxAttempts int
+	wake        chan struct{}
+
+	mu   sync.Mutex
+	subs map[string]*subscription
+}
+
+// NewEventBus creates a bus; EVENT_MAX_ATTEMPTS sets how often a failing delivery is tried (default 5)
+func NewEventBus(db *mongo.Database) *EventBus {
+	maxAttempts := 5
+	if n, err := strconv.Atoi(os.Getenv("EVENT_MAX_ATTEMPTS")); err == nil && n > 0 {
+		maxAttempts = n
+	}
+	return &EventBus{
+		db:          db,
+		maxAttempts: maxAttempts,
+		wake:        make(chan struct{}, 1),
+		subs:        make(map[string]*subscription),
+	}
+}
+
+// EnsureEventIndexes creates the indexes the workers poll with; it runs at startup
+func EnsureEventIndexes(db *mongo.Database) error {
+	_, err := db.Collection("EventDeliveries").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "lease_until", Value: 1}}},
+		{
+			// one waiting delivery per coalescing worker and user
+			Keys: bson.D{{Key: "coalesce_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"coalesce_key": bson.M{"$exists": true},
+				"status":       "pending",
+			}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("FailedEvents").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "worker", Value: 1}, {Key: "failed_at", Value: -1}}},
+		{Keys: bson.D{{Key: "event._id", Value: 1}}},
+	})
+	return err
+}
+
+// Subscribe registers a worker for some event types. A coalescing worker runs once
+// per user for a burst of events instead of once per event
+func (b *EventBus) Subscribe(name string, worker EventWorker, coalesce bool, types ...string) {
+	sub := &subscription{name: name, types: make(map[string]bool), worker: worker, coalesce: coalesce}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+	b.mu.Lock()
+	b.subs[name] = sub
+	b.mu.Unlock()
+}
+
+// Start runs the workers. They take deliveries from the collection as fast as they
+// get through them, so a burst of events waits in MongoDB instead of in memory
+func (b *EventBus) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				for b.runNext() {
+				}
+				select {
+				case <-b.wake:
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// Publish stores a delivery of the event for every subscriber; the workers run them later
+func (b *EventBus) Publish(e DomainEvent) {
+	if e.ID.IsZero() {
+		e.ID = primitive.NewObjectID()
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	for i := range e.Score {
+		if e.Score[i].ID.IsZero() {
+			e.Score[i].ID = primitive.NewObjectID()
+		}
+	}
+
+	b.mu.Lock()
+	var subs []*subscription
+	for _, sub := range b.subs {
+		if sub.types[e.Type] {
+			subs = append(subs, sub)
+		}
+	}
+	b.mu.Unlock()
+
+	deliveriesCol := b.db.Collection("EventDeliveries")
+	now := time.Now()
+	for _, sub := range subs {
+		d := eventDelivery{Worker: sub.name, Event: e, Status: "pending", NextAttemptAt: now}
+		var err error
+		if sub.coalesce {
+			// joins the user's delivery that hasn't started yet, if there is one
+			key := sub.name + ":" + e.UserID.Hex()
+			for try := 0; try < 2; try++ {
+				_, err = deliveriesCol.UpdateOne(context.Background(),
+					bson.M{"coalesce_key": key, "status": "pending"},
+					bson.M{"$setOnInsert": bson.M{
+						"worker":          d.Worker,
+						"event":           d.Event,
+						"attempts":        0,
+						"next_attempt_at": d.NextAttemptAt,
+					}},
+					options.Update().SetUpsert(true),
+				)
+				// another publish inserted it first; the retry joins that one
+				if !mongo.IsDuplicateKeyError(err) {
+					break
+				}
+			}
+		} else {
+			_, err = deliveriesCol.InsertOne(context.Background(), d)
+		}
+		if err != nil {
+			log.Printf("event %s (%s) could not be queued for %s: %v", e.ID.Hex(), e.Type, sub.name, err)
+		}
+	}
+
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runNext claims one due delivery and runs it; false when nothing is due
+func (b *EventBus) runNext() bool {
+	deliveriesCol := b.db.Collection("EventDeliveries")
+	now := time.Now()
+
+	var d eventDelivery
+	err := deliveriesCol.FindOneAndUpdate(context.Background(),
+		bson.M{"$or": bson.A{
+			bson.M{"status": "pending", "next_attempt_at": bson.M{"$lte": now}},
+			bson.M{"status": "running", "lease_until": bson.M{"$lt": now}}, // its worker died
+		}},
+		bson.M{"$set": bson.M{"status": "running", "lease_until": now.Add(eventLease)}, "$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetSort(bson.M{"next_attempt_at": 1}).SetReturnDocument(options.After),
+	).Decode(&d)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("event bus: %v", err)
+		}
+		return false
+	}
+
+	b.mu.Lock()
+	sub := b.subs[d.Worker]
+	b.mu.Unlock()
+	if sub == nil {
+		// the worker is gone, nothing will ever handle this
+		_, _ = deliveriesCol.DeleteOne(context.Background(), bson.M{"_id": d.ID})
+		return true
+	}
+
+	err = sub.worker(b.db, d.Event)
+	if err == nil {
+		_, _ = deliveriesCol.DeleteOne(context.Background(), bson.M{"_id": d.ID})
+		return true
+	}
+	if d.Attempts >= b.maxAttempts {
+		log.Printf("event %s (%s) failed in %s after %d attempts: %v", d.Event.ID.Hex(), d.Event.Type, d.Worker, d.Attempts, err)
+		_, _ = b.db.Collection("FailedEvents").InsertOne(context.Background(), map[string]any{
+			"event":     d.Event,
+			"worker":    d.Worker,
+			"attempts":  d.Attempts,
+			"error":     err.Error(),
+			"failed_at": time.Now(),
+		})
+		_, _ = deliveriesCol.DeleteOne(context.Background(), bson.M{"_id": d.ID})
+		return true
+	}
+
+	// back off 1s, 2s, 4s... before trying again
+	backoff := time.Second << (d.Attempts - 1)
+	_, _ = deliveriesCol.UpdateOne(context.Background(), bson.M{"_id": d.ID}, bson.M{
+		"$set":   bson.M{"status": "pending", "next_attempt_at": time.Now().Add(backoff)},
+		"$unset": bson.M{"coalesce_key": ""},
+	})
+	return true
+}
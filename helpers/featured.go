@@ -0,0 +1,183 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+// featuredCandidates is how many of the most upvoted items the daily pick is drawn from
+const featuredCandidates = 10
+
+// ErrNothingToFeature is returned when there is no eligible quote or review yet
+var ErrNothingToFeature = errors.New("nothing to feature")
+
+// featuredRepeatWindow is how long an item stays ineligible after being featured,
+// set in days with FEATURED_REPEAT_WINDOW_DAYS (default 30)
+func featuredRepeatWindow() time.Duration {
+	days := 30
+	if n, err := strconv.Atoi(os.Getenv("FEATURED_REPEAT_WINDOW_DAYS")); err == nil && n >= 0 {
+		days = n
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// FeaturedPeriod returns the period key for a kind: the day for quotes and the
+// Monday of the week for reviews
+func FeaturedPeriod(kind string, now time.Time) string {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if kind == "review" {
+		offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+		day = day.AddDate(0, 0, -offset)
+	}
+	return day.Format("2006-01-02")
+}
+
+// CurrentFeatured returns the pick for the current period, selecting and storing
+// one the first time the period is asked for
+func CurrentFeatured(db *mongo.Database, kind string, now time.Time) (models.FeaturedPick, error) {
+	picksCol := db.Collection("FeaturedPicks")
+	period := FeaturedPeriod(kind, now)
+
+	var pick models.FeaturedPick
+	err := picksCol.FindOne(context.Background(), bson.M{"kind": kind, "period": period}).Decode(&pick)
+	if err == nil {
+		return pick, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return pick, err
+	}
+
+	contentID, err := selectFeatured(db, kind, period, now)
+	if err != nil {
+		return pick, err
+	}
+
+	// $setOnInsert keeps the first choice if two requests race for the same period
+	_, err = picksCol.UpdateOne(context.Background(),
+		bson.M{"kind": kind, "period": period},
+		bson.M{"$setOnInsert": bson.M{
+			"content_id": contentID,
+			"pinned":     false,
+			"created_at": now,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return pick, err
+	}
+	err = picksCol.FindOne(context.Background(), bson.M{"kind": kind, "period": period}).Decode(&pick)
+	return pick, err
+}
+
+// ReplaceFeatured selects again when the current pick was deleted or hidden since
+// it was chosen. Only the first of several racing requests replaces it
+func ReplaceFeatured(db *mongo.Database, stale models.FeaturedPick, now time.Time) (models.FeaturedPick, error) {
+	picksCol := db.Collection("FeaturedPicks")
+	var pick models.FeaturedPick
+
+	contentID, err := selectFeatured(db, stale.Kind, stale.Period, now)
+	if err != nil {
+		return pick, err
+	}
+	_, err = picksCol.UpdateOne(context.Background(),
+		bson.M{"_id": stale.ID, "content_id": stale.ContentID},
+		bson.M{
+			"$set":   bson.M{"content_id": contentID, "pinned": false, "created_at": now},
+			"$unset": bson.M{"pinned_by": ""},
+		},
+	)
+	if err != nil {
+		return pick, err
+	}
+	err = picksCol.FindOne(context.Background(), bson.M{"kind": stale.Kind, "period": stale.Period}).Decode(&pick)
+	return pick, err
+}
+
+// PinFeatured lets an admin override the pick for the current period
+func PinFeatured(db *mongo.Database, kind string, contentID, adminID primitive.ObjectID, now time.Time) error {
+	_, err := db.Collection("FeaturedPicks").UpdateOne(context.Background(),
+		bson.M{"kind": kind, "period": FeaturedPeriod(kind, now)},
+		bson.M{
+			"$set": bson.M{
+				"content_id": contentID,
+				"pinned":     true,
+				"pinned_by":  adminID,
+			},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// UnpinFeatured drops the current pick so the selector chooses again
+func UnpinFeatured(db *mongo.Database, kind string, now time.Time) error {
+	_, err := db.Collection("FeaturedPicks").DeleteOne(context.Background(), bson.M{
+		"kind":   kind,
+		"period": FeaturedPeriod(kind, now),
+	})
+	return err
+}
+
+// selectFeatured draws from the most upvoted eligible items using a hash of the
+// period, so every server instance makes the same choice
+func selectFeatured(db *mongo.Database, kind, period string, now time.Time) (primitive.ObjectID, error) {
+	// items featured recently are skipped
+	recent, err := db.Collection("FeaturedPicks").Distinct(context.Background(), "content_id", bson.M{
+		"kind":       kind,
+		"created_at": bson.M{"$gte": now.Add(-featuredRepeatWindow())},
+	})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if recent == nil {
+		recent = []interface{}{}
+	}
+
+	filter := bson.M{
+		"_id":     bson.M{"$nin": recent},
+		"hidden":  bson.M{"$ne": true},
+		"upvotes": bson.M{"$gt": 0},
+	}
+	collection := "Quotes"
+	if kind == "review" {
+		collection = "Reviews"
+		filter["posted"] = true
+		filter["ai_check_status"] = "approved"
+		filter["book_deleted"] = false
+	}
+
+	cursor, err := db.Collection(collection).Find(context.Background(), filter,
+		options.Find().
+			SetSort(bson.D{{Key: "upvotes", Value: -1}, {Key: "_id", Value: 1}}).
+			SetLimit(featuredCandidates).
+			SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	var candidates []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(context.Background(), &candidates); err != nil {
+		return primitive.NilObjectID, err
+	}
+	if len(candidates) == 0 {
+		return primitive.NilObjectID, ErrNothingToFeature
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(kind + ":" + period))
+	return candidates[int(h.Sum32()%uint32(len(candidates)))].ID, nil
+}
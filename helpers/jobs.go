@@ -0,0 +1,106 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RegisterJobs adds the recurring library tasks to the scheduler
+func RegisterJobs(s *Scheduler) error {
+	jobs := []struct {
+		name, spec, description string
+		run                     JobFunc
+	}{
+		{"class-tags", "0 2 * * *", "re-evaluate every reader's class tag, tiers depend on time passing", classTagsJob},
+		{"notification-digest", "0 18 * * *", "send the daily digest of notifications held back by preferences", notificationDigestJob},
+		{"notification-cleanup", "30 3 * * *", "delete seen notifications older than NOTIFICATION_RETENTION_DAYS", notificationCleanupJob},
+		{"loan-reminders", "0 8 * * *", "remind readers of due and overdue loans and report long overdue ones to admins", SendLoanReminders},
+		{"streak-reset", "5 0 * * *", "reset reading streaks that were not kept up yesterday", ResetBrokenStreaks},
+		{"analytics-snapshot", "55 23 * * *", "store the day's library numbers in AnalyticsSnapshots", AnalyticsSnapshot},
+	}
+	for _, j := range jobs {
+		if err := s.Register(j.name, j.spec, j.description, j.run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func classTagsJob(db *mongo.Database) (string, error) {
+	changed, err := ReevaluateAllClassTags(db)
+	return fmt.Sprintf("%d readers changed tier", changed), err
+}
+
+func notificationDigestJob(db *mongo.Database) (string, error) {
+	sent, err := BuildDigests(db)
+	return fmt.Sprintf("%d digests sent", sent), err
+}
+
+func notificationCleanupJob(db *mongo.Database) (string, error) {
+	purged, err := PurgeOldNotifications(db)
+	return fmt.Sprintf("%d notifications deleted", purged), err
+}
+
+// ResetBrokenStreaks sets the streak of books not updated today or yesterday back to
+// zero, so streaks shown between updates are not stale
+func ResetBrokenStreaks(db *mongo.Database) (string, error) {
+	// same day boundaries as UpdateReadingProgress
+	yesterday := time.Now().Truncate(24 * time.Hour).Add(-24 * time.Hour)
+	res, err := db.Collection("ReadingProgress").UpdateMany(context.Background(),
+		bson.M{"completed": false, "streak_days": bson.M{"$gt": 0}, "last_updated": bson.M{"$lt": yesterday}},
+		bson.M{"$set": bson.M{"streak_days": 0}},
+	)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d streaks reset", res.ModifiedCount), nil
+}
+
+// AnalyticsSnapshot stores today's library numbers, one document per day
+func AnalyticsSnapshot(db *mongo.Database) (string, error) {
+	ctx := context.Background()
+	now := time.Now()
+	day := now.Truncate(24 * time.Hour)
+
+	counts := []struct {
+		key        string
+		collection string
+		filter     bson.M
+	}{
+		{"readers", "users", bson.M{"role": bson.M{"$ne": "admin"}}},
+		{"new_readers", "users", bson.M{"role": bson.M{"$ne": "admin"}, "created_at": bson.M{"$gte": day}}},
+		{"pending_registrations", "pending_registrations", bson.M{}},
+		{"books", "books", bson.M{}},
+		{"books_borrowed", "books", bson.M{"available": false}},
+		{"borrows_today", "BorrowHistory", bson.M{"borrow_date": bson.M{"$gte": day}}},
+		{"returns_today", "BorrowHistory", bson.M{"return_date": bson.M{"$gte": day}}},
+		{"reviews_approved", "Reviews", bson.M{"ai_check_status": "approved"}},
+		{"quotes", "Quotes", bson.M{}},
+		{"badges", "Badges", bson.M{}},
+	}
+	snapshot := bson.M{"date": day, "taken_at": now}
+	for _, c := range counts {
+		n, err := db.Collection(c.collection).CountDocuments(ctx, c.filter)
+		if err != nil {
+			return "", err
+		}
+		snapshot[c.key] = n
+	}
+
+	active, err := db.Collection("ReadingProgress").Distinct(ctx, "user_id", bson.M{"last_updated": bson.M{"$gte": now.AddDate(0, 0, -7)}})
+	if err != nil {
+		return "", err
+	}
+	snapshot["active_readers_7d"] = len(active)
+
+	_, err = db.Collection("AnalyticsSnapshots").ReplaceOne(ctx, bson.M{"date": day}, snapshot, options.Replace().SetUpsert(true))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d active readers this week", len(active)), nil
+}
@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrUnknownPeriod is returned for a leaderboard period we don't support
+var ErrUnknownPeriod = errors.New("period must be weekly, monthly, semester or all-time")
+
+// semesterStartMonths reads SEMESTER_START_MONTHS (e.g. "2,9" for February and
+// September), defaulting to those two
+func semesterStartMonths() []time.Month {
+	months := []time.Month{}
+	for _, part := range strings.Split(os.Getenv("SEMESTER_START_MONTHS"), ",") {
+		if m, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && m >= 1 && m <= 12 {
+			months = append(months, time.Month(m))
+		}
+	}
+	if len(months) == 0 {
+		months = []time.Month{time.February, time.September}
+	}
+	return months
+}
+
+// PeriodStart returns when a leaderboard period began; the zero time means all-time
+func PeriodStart(period string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case "", "all-time", "all":
+		return time.Time{}, nil
+	case "weekly", "week":
+		// weeks start on Monday
+		offset := (int(today.Weekday()) + 6) % 7
+		return today.AddDate(0, 0, -offset), nil
+	case "monthly", "month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), nil
+	case "semester":
+		// the latest semester start that is not in the future
+		var start time.Time
+		for _, year := range []int{now.Year() - 1, now.Year()} {
+			for _, m := range semesterStartMonths() {
+				candidate := time.Date(year, m, 1, 0, 0, 0, 0, now.Location())
+				if !candidate.After(now) && candidate.After(start) {
+					start = candidate
+				}
+			}
+		}
+		return start, nil
+	}
+	return time.Time{}, ErrUnknownPeriod
+}
+
+// PeriodScores sums each user's score events since a time. Opening balances are
+// left out, they are dated when the ledger was introduced, not when they were earned
+func PeriodScores(db *mongo.Database, since time.Time) (map[primitive.ObjectID]int, error) {
+	return ledgerTotals(db, bson.M{
+		"created_at": bson.M{"$gte": since},
+		"reason":     bson.M{"$ne": ScoreReasonOpeningBalance},
+	})
+}
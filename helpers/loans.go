@@ -0,0 +1,180 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"reading-tracker/backend/models"
+)
+
+func envDays(name string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n >= 0 {
+		return n
+	}
+	return def
+}
+
+// LoanDays is how long a hardcopy can be kept, LOAN_DAYS (default 14)
+func LoanDays() int {
+	return envDays("LOAN_DAYS", 14)
+}
+
+// overdueReminderDays are the days after the due date a reader is reminded again,
+// OVERDUE_REMINDER_DAYS (default "1,3,7")
+func overdueReminderDays() []int {
+	spec := os.Getenv("OVERDUE_REMINDER_DAYS")
+	if spec == "" {
+		spec = "1,3,7"
+	}
+	var days []int
+	for _, part := range strings.Split(spec, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && n > 0 {
+			days = append(days, n)
+		}
+	}
+	sort.Ints(days)
+	return days
+}
+
+// LoanDueDate is when a loan has to be back; loans from before due dates existed
+// are due LoanDays after they were borrowed
+func LoanDueDate(loan models.BorrowHistory) time.Time {
+	if !loan.DueDate.IsZero() {
+		return loan.DueDate
+	}
+	return loan.BorrowDate.AddDate(0, 0, LoanDays())
+}
+
+// daysOverdue counts whole days past the due date (negative before it)
+func daysOverdue(due, now time.Time) int {
+	return int(now.Truncate(24*time.Hour).Sub(due.Truncate(24*time.Hour)).Hours() / 24)
+}
+
+// openLoans are the hardcopies a reader (or everyone, with a nil id) has not returned
+func openLoans(db *mongo.Database, userID primitive.ObjectID) ([]models.BorrowHistory, error) {
+	filter := bson.M{"return_date": bson.M{"$exists": false}}
+	if !userID.IsZero() {
+		filter["user_id"] = userID
+	}
+	cursor, err := db.Collection("BorrowHistory").Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	var loans []models.BorrowHistory
+	err = cursor.All(context.Background(), &loans)
+	return loans, err
+}
+
+// BorrowBlocked reports whether a reader has a loan more than BORROW_BLOCK_OVERDUE_DAYS
+// (default 0) days overdue, which keeps them from borrowing another book
+func BorrowBlocked(db *mongo.Database, userID primitive.ObjectID) (bool, error) {
+	loans, err := openLoans(db, userID)
+	if err != nil {
+		return false, err
+	}
+	grace := envDays("BORROW_BLOCK_OVERDUE_DAYS", 0)
+	now := time.Now()
+	for _, loan := range loans {
+		if daysOverdue(LoanDueDate(loan), now) > grace {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// claimReminder marks a reminder as sent on the loan; false when it already was,
+// so a reminder goes out once even when the job runs twice
+func claimReminder(db *mongo.Database, loanID primitive.ObjectID, key string) bool {
+	res, err := db.Collection("BorrowHistory").UpdateOne(context.Background(),
+		bson.M{"_id": loanID, "reminders_sent": bson.M{"$ne": key}},
+		bson.M{"$addToSet": bson.M{"reminders_sent": key}},
+	)
+	return err == nil && res.ModifiedCount == 1
+}
+
+// SendLoanReminders reminds readers REMINDER_DAYS_BEFORE (default 2) days before a
+// loan is due, on the due date and on each of the overdue reminder days. Loans
+// OVERDUE_ESCALATE_DAYS (default 7) days late are reported to the admins
+func SendLoanReminders(db *mongo.Database) (string, error) {
+	loans, err := openLoans(db, primitive.NilObjectID)
+	if err != nil {
+		return "", err
+	}
+
+	daysBefore := envDays("REMINDER_DAYS_BEFORE", 2)
+	escalateAfter := envDays("OVERDUE_ESCALATE_DAYS", 7)
+	overdueDays := overdueReminderDays()
+
+	var admins []models.User
+	if cursor, err := db.Collection("users").Find(context.Background(), bson.M{"role": "admin"}); err == nil {
+		_ = cursor.All(context.Background(), &admins)
+	}
+
+	now := time.Now()
+	sent, escalated := 0, 0
+	for _, loan := range loans {
+		due := LoanDueDate(loan)
+		late := daysOverdue(due, now)
+
+		// only the latest reminder that applies goes out, the ones it replaces are skipped
+		key, notifType := "", ""
+		switch {
+		case late < 0 && -late <= daysBefore:
+			key, notifType = "due_soon", "loan_due_soon"
+		case late == 0:
+			key, notifType = "due_today", "loan_due_today"
+		case late > 0:
+			for _, d := range overdueDays {
+				if late >= d {
+					key, notifType = "overdue_"+strconv.Itoa(d), "loan_overdue"
+				}
+			}
+		}
+
+		if key != "" && claimReminder(db, loan.ID, key) {
+			sent++
+			if err := CreateNotification(db, loan.UserID, primitive.NilObjectID, loan.BookID, notifType); err != nil {
+				log.Printf("loan reminder %s for %s: %v", key, loan.ID.Hex(), err)
+			}
+			var user models.User
+			if err := db.Collection("users").FindOne(context.Background(), bson.M{"_id": loan.UserID}).Decode(&user); err == nil {
+				_ = QueueEmail(db, user.Email, MailOverdueReminder, map[string]any{
+					"Name":    user.Name,
+					"Title":   loanTitle(db, loan),
+					"DueDate": due.Format("Monday, 2 January 2006"),
+					"Overdue": late > 0,
+				})
+			}
+		}
+
+		if escalateAfter > 0 && late >= escalateAfter && claimReminder(db, loan.ID, "escalated") {
+			escalated++
+			for _, admin := range admins {
+				_ = CreateNotification(db, admin.ID, loan.UserID, loan.BookID, "loan_overdue_admin")
+			}
+		}
+	}
+	return fmt.Sprintf("%d reminders sent, %d loans escalated", sent, escalated), nil
+}
+
+// loanTitle is the book title of a loan; older records only have the book id
+func loanTitle(db *mongo.Database, loan models.BorrowHistory) string {
+	if loan.Title != "" {
+		return loan.Title
+	}
+	var book models.Book
+	if err := db.Collection("books").FindOne(context.Background(), bson.M{"_id": loan.BookID}).Decode(&book); err == nil {
+		return book.Title
+	}
+	return loan.ISBN
+}
@@ -0,0 +1,152 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	texttemplate "text/template"
+)
+
+// Email template names
+const (
+	MailRegistrationApproved = "registration_approved"
+	MailRegistrationRejected = "registration_rejected"
+	MailOverdueReminder      = "overdue_reminder"
+	MailHoldReady            = "hold_ready"
+	MailPasswordReset        = "password_reset"
+	MailDigest               = "digest"
+)
+
+type mailTemplate struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// mailTemplates are written with Go templates; every template also gets {{.BaseURL}}
+var mailTemplates = map[string]mailTemplate{
+	MailRegistrationApproved: {
+		Subject: "Your Reading Tracker registration was approved",
+		Text: `Hi {{.Name}},
+
+Your registration was approved, your reader id is {{.ReaderID}}.
+You can log in at {{.BaseURL}}/login with the email and password you registered with.
+
+Happy reading!`,
+		HTML: `<p>Hi {{.Name}},</p>
+<p>Your registration was approved, your reader id is <strong>{{.ReaderID}}</strong>.</p>
+<p>You can <a href="{{.BaseURL}}/login">log in</a> with the email and password you registered with.</p>
+<p>Happy reading!</p>`,
+	},
+	MailRegistrationRejected: {
+		Subject: "Your Reading Tracker registration",
+		Text: `Hi {{.Name}},
+
+Sorry, your registration was not approved.{{if .Reason}}
+Reason: {{.Reason}}{{end}}
+
+Please contact a librarian if you think this is a mistake.`,
+		HTML: `<p>Hi {{.Name}},</p>
+<p>Sorry, your registration was not approved.</p>
+{{if .Reason}}<p>Reason: {{.Reason}}</p>{{end}}
+<p>Please contact a librarian if you think this is a mistake.</p>`,
+	},
+	MailOverdueReminder: {
+		Subject: "{{.Title}} is {{if .Overdue}}overdue{{else}}due soon{{end}}",
+		Text: `Hi {{.Name}},
+
+{{if .Overdue}}"{{.Title}}" was due on {{.DueDate}}, please return it as soon as you can.{{else}}"{{.Title}}" is due on {{.DueDate}}, please return it or renew it before then.{{end}}
+
+{{.BaseURL}}/borrow-history`,
+		HTML: `<p>Hi {{.Name}},</p>
+{{if .Overdue}}<p><strong>{{.Title}}</strong> was due on {{.DueDate}}, please return it as soon as you can.</p>{{else}}<p><strong>{{.Title}}</strong> is due on {{.DueDate}}, please return it or renew it before then.</p>{{end}}
+<p><a href="{{.BaseURL}}/borrow-history">Your borrowed books</a></p>`,
+	},
+	MailHoldReady: {
+		Subject: "{{.Title}} is ready for you",
+		Text: `Hi {{.Name}},
+
+"{{.Title}}" you put on hold is ready, please pick it up before {{.PickupBy}}.`,
+		HTML: `<p>Hi {{.Name}},</p>
+<p><strong>{{.Title}}</strong> you put on hold is ready, please pick it up before {{.PickupBy}}.</p>`,
+	},
+	MailPasswordReset: {
+		Subject: "Reset your Reading Tracker password",
+		Text: `Hi {{.Name}},
+
+Someone asked to reset your password. Open the link below within {{.ExpiresIn}} to pick a new one:
+{{.BaseURL}}/reset-password?token={{.Token}}
+
+If it wasn't you, you can ignore this email.`,
+		HTML: `<p>Hi {{.Name}},</p>
+<p>Someone asked to reset your password. <a href="{{.BaseURL}}/reset-password?token={{.Token}}">Pick a new one</a> within {{.ExpiresIn}}.</p>
+<p>If it wasn't you, you can ignore this email.</p>`,
+	},
+	MailDigest: {
+		Subject: "Your daily Reading Tracker digest",
+		Text: `Hi {{.Name}},
+
+Here is what happened today:
+{{range .Lines}}- {{.}}
+{{end}}
+{{.BaseURL}}/notifications`,
+		HTML: `<p>Hi {{.Name}},</p>
+<p>Here is what happened today:</p>
+<ul>{{range .Lines}}<li>{{.}}</li>{{end}}</ul>
+<p><a href="{{.BaseURL}}/notifications">See your notifications</a></p>`,
+	},
+}
+
+// mailBaseURL is where links in emails point, APP_BASE_URL (default http://localhost:3000)
+func mailBaseURL() string {
+	if u := os.Getenv("APP_BASE_URL"); u != "" {
+		return u
+	}
+	return "http://localhost:3000"
+}
+
+// RenderEmail fills in the named template for one recipient
+func RenderEmail(name, to string, data map[string]any) (Email, error) {
+	tmpl, ok := mailTemplates[name]
+	if !ok {
+		return Email{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	values := map[string]any{"BaseURL": mailBaseURL()}
+	for k, v := range data {
+		values[k] = v
+	}
+
+	msg := Email{To: to}
+	var err error
+	if msg.Subject, err = executeText(name+".subject", tmpl.Subject, values); err != nil {
+		return Email{}, err
+	}
+	if msg.Text, err = executeText(name+".text", tmpl.Text, values); err != nil {
+		return Email{}, err
+	}
+
+	h, err := htmltemplate.New(name + ".html").Option("missingkey=zero").Parse(tmpl.HTML)
+	if err != nil {
+		return Email{}, err
+	}
+	var buf bytes.Buffer
+	if err := h.Execute(&buf, values); err != nil {
+		return Email{}, err
+	}
+	msg.HTML = buf.String()
+	return msg, nil
+}
+
+func executeText(name, text string, values map[string]any) (string, error) {
+	t, err := texttemplate.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, values); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,120 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email is one rendered message ready to be handed to a Mailer
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers an email; the outbox retries when Send fails
+type Mailer interface {
+	Send(msg Email) error
+}
+
+// SMTPMailer sends through an SMTP server, e.g. MailHog on localhost:1025 during development
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send writes the email as multipart/alternative so clients can pick text or html
+func (m *SMTPMailer) Send(msg Email) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{msg.To}, buildMessage(m.From, msg))
+}
+
+func buildMessage(from string, msg Email) []byte {
+	boundaryBytes := make([]byte, 12)
+	_, _ = rand.Read(boundaryBytes)
+	boundary := "rt-" + hex.EncodeToString(boundaryBytes)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n", boundary)
+	b.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	if msg.HTML != "" {
+		fmt.Fprintf(&b, "\r\n--%s\r\nContent-Type: text/html; charset=utf-8\r\n\r\n", boundary)
+		b.WriteString(strings.ReplaceAll(msg.HTML, "\n", "\r\n"))
+	}
+	fmt.Fprintf(&b, "\r\n--%s--\r\n", boundary)
+	return b.Bytes()
+}
+
+// LogMailer doesn't send anything, it appends the text part to a file (or the log
+// when Path is empty) so emails can be read during development
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (m *LogMailer) Send(msg Email) error {
+	entry := fmt.Sprintf("=== %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Text)
+	if m.Path == "" {
+		log.Print("email not sent (log mailer):\n" + entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(entry)
+	return err
+}
+
+// NewMailerFromEnv picks the mailer with MAIL_DRIVER: "smtp" uses SMTP_HOST, SMTP_PORT
+// (default 1025), SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM; anything else logs to MAIL_LOG_FILE
+func NewMailerFromEnv() Mailer {
+	if os.Getenv("MAIL_DRIVER") != "smtp" {
+		return &LogMailer{Path: os.Getenv("MAIL_LOG_FILE")}
+	}
+
+	m := &SMTPMailer{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	}
+	if m.Host == "" {
+		m.Host = "localhost"
+	}
+	if m.Port == "" {
+		m.Port = "1025"
+	}
+	if m.From == "" {
+		m.From = "no-reply@reading-tracker.local"
+	}
+	return m
+}
@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxCommentDepth is how deep replies can nest; a top level comment has depth 0
+const MaxCommentDepth = 4
+
+var mentionPattern = regexp.MustCompile(`@([A-Za-z0-9_]+)`)
+
+// ParseMentions returns the distinct reader IDs mentioned as @reader_id in a text
+func ParseMentions(text string) []string {
+	seen := make(map[string]bool)
+	var readerIDs []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			readerIDs = append(readerIDs, m[1])
+		}
+	}
+	return readerIDs
+}
+
+// NotifyMentions sends a "mention" notification to every mentioned user, except
+// the ones listed in skip (they are already notified some other way)
+func NotifyMentions(db *mongo.Database, actorID, targetID primitive.ObjectID, text string, skip ...primitive.ObjectID) error {
+	return notifyMentions(db, actorID, targetID, text, nil, skip)
+}
+
+// NotifyMentionsAmong is NotifyMentions limited to the given users, for texts only
+// they may read such as a spoiler-gated club discussion
+func NotifyMentionsAmong(db *mongo.Database, actorID, targetID primitive.ObjectID, text string, allowed []primitive.ObjectID) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	return notifyMentions(db, actorID, targetID, text, allowed, nil)
+}
+
+func notifyMentions(db *mongo.Database, actorID, targetID primitive.ObjectID, text string, allowed, skip []primitive.ObjectID) error {
+	readerIDs := ParseMentions(text)
+	if len(readerIDs) == 0 {
+		return nil
+	}
+
+	filter := bson.M{"reader_id": bson.M{"$in": readerIDs}}
+	if allowed != nil {
+		filter["_id"] = bson.M{"$in": allowed}
+	}
+	cursor, err := db.Collection("users").Find(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var user struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			continue
+		}
+		skipped := false
+		for _, id := range skip {
+			if id == user.ID {
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			_ = CreateNotification(db, user.ID, actorID, targetID, "mention")
+		}
+	}
+	return cursor.Err()
+}
+
+// ReplyParent is the comment being replied to
+type ReplyParent struct {
+	ID     primitive.ObjectID `bson:"_id"`
+	UserID primitive.ObjectID `bson:"user_id"`
+	Depth  int                `bson:"depth"`
+}
+
+// FindReplyParent checks a parent comment exists under the same review or quote
+// and that a reply to it stays within MaxCommentDepth
+func FindReplyParent(db *mongo.Database, collection, parentField string, parentID, commentID primitive.ObjectID) (ReplyParent, error) {
+	var parent ReplyParent
+	err := db.Collection(collection).FindOne(context.Background(), bson.M{
+		"_id":       commentID,
+		parentField: parentID,
+	}).Decode(&parent)
+	if err != nil {
+		return parent, errors.New("parent comment not found")
+	}
+	if parent.Depth+1 > MaxCommentDepth {
+		return parent, errors.New("replies cannot be nested any deeper")
+	}
+	return parent, nil
+}
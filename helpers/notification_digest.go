@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"reading-tracker/backend/models"
+)
+
+// NotificationTypes lists every type a preference can be set for
+func NotificationTypes() []string {
+	types := make([]string, 0, len(notificationTexts))
+	for t := range notificationTexts {
+		types = append(types, t)
+	}
+	return types
+}
+
+// BuildDigests turns each user's held back notifications into one "digest"
+// notification and returns how many digests were sent. The items are first claimed
+// under an id that becomes the digest's _id, so a run that fails halfway is finished
+// by the next one without sending the same items twice
+func BuildDigests(db *mongo.Database) (int, error) {
+	queueCol := db.Collection("NotificationDigestQueue")
+
+	// claims left over from a run that stopped before cleaning up
+	stale, err := queueCol.Distinct(context.Background(), "digest_id", bson.M{"digest_id": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, id := range stale {
+		if digestID, ok := id.(primitive.ObjectID); ok {
+			if done, err := sendDigest(db, digestID); err != nil {
+				log.Printf("failed to finish digest %s: %v", digestID.Hex(), err)
+			} else if done {
+				sent++
+			}
+		}
+	}
+
+	userIDs, err := queueCol.Distinct(context.Background(), "user_id", bson.M{"digest_id": bson.M{"$exists": false}})
+	if err != nil {
+		return sent, err
+	}
+	for _, id := range userIDs {
+		userID, ok := id.(primitive.ObjectID)
+		if !ok {
+			continue
+		}
+		digestID := primitive.NewObjectID()
+		res, err := queueCol.UpdateMany(context.Background(),
+			bson.M{"user_id": userID, "digest_id": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"digest_id": digestID}},
+		)
+		if err != nil {
+			log.Printf("failed to claim digest items of %s: %v", userID.Hex(), err)
+			continue
+		}
+		if res.ModifiedCount == 0 {
+			continue
+		}
+		if done, err := sendDigest(db, digestID); err != nil {
+			log.Printf("failed to send digest to %s: %v", userID.Hex(), err)
+		} else if done {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// sendDigest sends the digest of the items claimed under digestID, unless an
+// earlier run already did, and then removes them from the queue
+func sendDigest(db *mongo.Database, digestID primitive.ObjectID) (bool, error) {
+	queueCol := db.Collection("NotificationDigestQueue")
+
+	count, err := db.Collection("Notifications").CountDocuments(context.Background(), bson.M{"_id": digestID})
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		_, err := queueCol.DeleteMany(context.Background(), bson.M{"digest_id": digestID})
+		return false, err
+	}
+
+	cursor, err := queueCol.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"digest_id": digestID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$type",
+			"user_id": bson.M{"$first": "$user_id"},
+			"count":   bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return false, err
+	}
+	defer cursor.Close(context.Background())
+
+	var userID primitive.ObjectID
+	summary := make(map[string]int)
+	for cursor.Next(context.Background()) {
+		var row struct {
+			Type   string             `bson:"_id"`
+			UserID primitive.ObjectID `bson:"user_id"`
+			Count  int                `bson:"count"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return false, err
+		}
+		userID = row.UserID
+		summary[row.Type] = row.Count
+	}
+	if err := cursor.Err(); err != nil {
+		return false, err
+	}
+	if len(summary) == 0 {
+		return false, nil
+	}
+
+	err = insertNotification(db, models.Notification{
+		ID:        digestID,
+		UserID:    userID,
+		Type:      "digest",
+		Summary:   summary,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return false, err
+	}
+	if _, err := queueCol.DeleteMany(context.Background(), bson.M{"digest_id": digestID}); err != nil {
+		// the next run sees the digest exists and only cleans up
+		log.Printf("failed to clear digest %s from the queue: %v", digestID.Hex(), err)
+	}
+
+	var user models.User
+	if err := db.Collection("users").FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user); err == nil {
+		if err := QueueEmail(db, user.Email, MailDigest, map[string]any{"Name": user.Name, "Lines": digestLines(summary)}); err != nil {
+			log.Printf("failed to queue digest email for %s: %v", userID.Hex(), err)
+		}
+	}
+	return true, nil
+}
+
+// notificationRetention is how long seen notifications are kept, NOTIFICATION_RETENTION_DAYS (default 90)
+func notificationRetention() time.Duration {
+	days := 90
+	if n, err := strconv.Atoi(os.Getenv("NOTIFICATION_RETENTION_DAYS")); err == nil && n > 0 {
+		days = n
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// PurgeOldNotifications deletes seen notifications past the retention period
+func PurgeOldNotifications(db *mongo.Database) (int64, error) {
+	res, err := db.Collection("Notifications").DeleteMany(context.Background(), bson.M{
+		"seen":       true,
+		"created_at": bson.M{"$lt": time.Now().Add(-notificationRetention())},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
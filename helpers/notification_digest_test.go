@@ -0,0 +1,166 @@
+package helpers
+
+import (
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// commandNames lists the commands the mock deployment received, in order
+func commandNames(mt *mtest.T) []string {
+	var names []string
+	for _, e := range mt.GetAllStartedEvents() {
+		names = append(names, e.CommandName)
+	}
+	return names
+}
+
+func TestSendDigest(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	digestID := primitive.NewObjectID()
+	userID := primitive.NewObjectID()
+	count := func(n int) bson.D {
+		if n == 0 {
+			return mtest.CreateCursorResponse(0, "db.Notifications", mtest.FirstBatch)
+		}
+		return mtest.CreateCursorResponse(0, "db.Notifications", mtest.FirstBatch, bson.D{{Key: "n", Value: n}})
+	}
+	grouped := mtest.CreateCursorResponse(0, "db.NotificationDigestQueue", mtest.FirstBatch,
+		bson.D{{Key: "_id", Value: "mention"}, {Key: "user_id", Value: userID}, {Key: "count", Value: 2}},
+		bson.D{{Key: "_id", Value: "reply"}, {Key: "user_id", Value: userID}, {Key: "count", Value: 1}},
+	)
+	ok := mtest.CreateSuccessResponse()
+	deleted := mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 3})
+	noUser := mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch)
+	insertFailed := mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Message: "bad value", Name: "BadValue"})
+
+	tests := []struct {
+		name      string
+		responses []bson.D
+		wantSent  bool
+		wantErr   bool
+		wantCmds  []string
+	}{
+		{
+			name:      "sends and clears the claimed items",
+			responses: []bson.D{count(0), grouped, ok, deleted, noUser},
+			wantSent:  true,
+			wantCmds:  []string{"aggregate", "aggregate", "insert", "delete", "find"},
+		},
+		{
+			name:      "a digest sent by an earlier run is only cleaned up",
+			responses: []bson.D{count(1), deleted},
+			wantCmds:  []string{"aggregate", "delete"},
+		},
+		{
+			name:      "nothing claimed",
+			responses: []bson.D{count(0), mtest.CreateCursorResponse(0, "db.NotificationDigestQueue", mtest.FirstBatch)},
+			wantCmds:  []string{"aggregate", "aggregate"},
+		},
+		{
+			name:      "a failed insert keeps the claim for the next run",
+			responses: []bson.D{count(0), grouped, insertFailed},
+			wantErr:   true,
+			wantCmds:  []string{"aggregate", "aggregate", "insert"},
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(tt.responses...)
+
+			sent, err := sendDigest(mt.DB, digestID)
+			if (err != nil) != tt.wantErr {
+				mt.Fatalf("sendDigest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if sent != tt.wantSent {
+				mt.Errorf("sendDigest() sent = %v, want %v", sent, tt.wantSent)
+			}
+			if got := commandNames(mt); !slices.Equal(got, tt.wantCmds) {
+				mt.Errorf("commands = %v, want %v", got, tt.wantCmds)
+			}
+		})
+	}
+
+	mt.Run("the digest takes the claim's id and summary", func(mt *mtest.T) {
+		mt.AddMockResponses(count(0), grouped, ok, deleted, noUser)
+		if _, err := sendDigest(mt.DB, digestID); err != nil {
+			mt.Fatal(err)
+		}
+
+		var insert, del bson.Raw
+		for _, e := range mt.GetAllStartedEvents() {
+			switch e.CommandName {
+			case "insert":
+				insert = e.Command
+			case "delete":
+				del = e.Command
+			}
+		}
+		doc := insert.Lookup("documents", "0").Document()
+		if id := doc.Lookup("_id").ObjectID(); id != digestID {
+			mt.Errorf("digest _id = %s, want the claim id %s", id.Hex(), digestID.Hex())
+		}
+		if n := doc.Lookup("summary", "mention").Int32(); n != 2 {
+			mt.Errorf("summary mention = %d, want 2", n)
+		}
+		if id := del.Lookup("deletes", "0", "q", "digest_id").ObjectID(); id != digestID {
+			mt.Errorf("deleted digest_id = %s, want %s", id.Hex(), digestID.Hex())
+		}
+	})
+}
+
+func TestBuildDigestsResumesClaims(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("an unfinished claim is sent before new items are claimed", func(mt *mtest.T) {
+		stale := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{stale}}), // claims left over
+			mtest.CreateCursorResponse(0, "db.Notifications", mtest.FirstBatch, bson.D{{Key: "n", Value: 1}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}),             // clean up only
+			mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{}}), // no new users
+		)
+
+		sent, err := BuildDigests(mt.DB)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if sent != 0 {
+			mt.Errorf("BuildDigests() = %d, want 0 for a digest that was already sent", sent)
+		}
+		want := []string{"distinct", "aggregate", "delete", "distinct"}
+		if got := commandNames(mt); !slices.Equal(got, want) {
+			mt.Errorf("commands = %v, want %v", got, want)
+		}
+	})
+
+	mt.Run("new items are claimed under a fresh digest id", func(mt *mtest.T) {
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{userID}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}), // someone else claimed them
+		)
+
+		sent, err := BuildDigests(mt.DB)
+		if err != nil || sent != 0 {
+			mt.Fatalf("BuildDigests() = %d, %v, want 0, nil", sent, err)
+		}
+		var update bson.Raw
+		for _, e := range mt.GetAllStartedEvents() {
+			if e.CommandName == "update" {
+				update = e.Command
+			}
+		}
+		if update == nil {
+			mt.Fatal("no claim was written")
+		}
+		if _, err := update.LookupErr("updates", "0", "q", "digest_id", "$exists"); err != nil {
+			mt.Errorf("the claim must only take unclaimed items: %v", err)
+		}
+	})
+}
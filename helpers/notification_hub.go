@@ -0,0 +1,127 @@
+package helpers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+// NotificationHub fans new notifications out to the streams open in this process
+type NotificationHub struct {
+	mu   sync.Mutex
+	subs map[primitive.ObjectID]map[chan models.Notification]struct{}
+
+	// while a change stream is feeding the hub, notifications written by any
+	// instance arrive through it, so CreateNotification must not publish them again
+	streaming atomic.Bool
+}
+
+// Notifications is the hub CreateNotification publishes to
+var Notifications = NewNotificationHub()
+
+func NewNotificationHub() *NotificationHub {
+	return &NotificationHub{subs: make(map[primitive.ObjectID]map[chan models.Notification]struct{})}
+}
+
+// Subscribe opens a feed of a user's new notifications. The channel is closed when
+// the subscriber falls too far behind; it should then reconnect and resume
+func (h *NotificationHub) Subscribe(userID primitive.ObjectID) (<-chan models.Notification, func()) {
+	ch := make(chan models.Notification, 32)
+	h.mu.Lock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[chan models.Notification]struct{})
+	}
+	h.subs[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() { h.remove(userID, ch) }
+}
+
+func (h *NotificationHub) remove(userID primitive.ObjectID, ch chan models.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[userID][ch]; !ok {
+		return
+	}
+	delete(h.subs[userID], ch)
+	if len(h.subs[userID]) == 0 {
+		delete(h.subs, userID)
+	}
+	close(ch)
+}
+
+// Publish hands a notification to every open stream of its recipient
+func (h *NotificationHub) Publish(n models.Notification) {
+	h.mu.Lock()
+	var behind []chan models.Notification
+	for ch := range h.subs[n.UserID] {
+		select {
+		case ch <- n:
+		default:
+			behind = append(behind, ch)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, ch := range behind {
+		h.remove(n.UserID, ch)
+	}
+}
+
+// publishLocal is used by CreateNotification when no change stream is running
+func (h *NotificationHub) publishLocal(n models.Notification) {
+	if !h.streaming.Load() {
+		h.Publish(n)
+	}
+}
+
+// WatchNotifications feeds the hub from a MongoDB change stream on Notifications, so
+// notifications written by other server instances reach streams open on this one.
+// Change streams need a replica set; on a standalone server the hub falls back to
+// the notifications this instance writes and the watcher tries again later
+func WatchNotifications(db *mongo.Database, hub *NotificationHub) {
+	go func() {
+		var resumeToken bson.Raw
+		for {
+			opts := options.ChangeStream()
+			if resumeToken != nil {
+				opts.SetResumeAfter(resumeToken)
+			}
+			stream, err := db.Collection("Notifications").Watch(context.Background(), mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"operationType": "insert"}}},
+			}, opts)
+			if err != nil {
+				hub.streaming.Store(false)
+				log.Printf("notification change stream unavailable, serving local notifications only: %v", err)
+				resumeToken = nil
+				time.Sleep(5 * time.Minute)
+				continue
+			}
+
+			hub.streaming.Store(true)
+			for stream.Next(context.Background()) {
+				var change struct {
+					FullDocument models.Notification `bson:"fullDocument"`
+				}
+				if err := stream.Decode(&change); err != nil {
+					continue
+				}
+				hub.Publish(change.FullDocument)
+				resumeToken = stream.ResumeToken()
+			}
+			hub.streaming.Store(false)
+			log.Printf("notification change stream closed: %v", stream.Err())
+			stream.Close(context.Background())
+			time.Sleep(5 * time.Second)
+		}
+	}()
+}
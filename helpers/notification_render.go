@@ -0,0 +1,444 @@
+package helpers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+// RenderedActor is who triggered a notification
+type RenderedActor struct {
+	ID       primitive.ObjectID `json:"id"`
+	Name     string             `json:"name"`
+	ReaderID string             `json:"reader_id"`
+}
+
+// RenderedTarget summarises what a notification is about
+type RenderedTarget struct {
+	Kind      string             `json:"kind"` // "review", "quote", "review_comment", "book", "club"...
+	ID        primitive.ObjectID `json:"id"`
+	Name      string             `json:"name,omitempty"` // club, badge or user name
+	Snippet   string             `json:"snippet,omitempty"`
+	BookTitle string             `json:"book_title,omitempty"`
+	ISBN      string             `json:"isbn,omitempty"`
+	Link      string             `json:"-"`
+}
+
+// RenderedNotification is a notification, or a group of alike notifications, ready to show
+type RenderedNotification struct {
+	ID         primitive.ObjectID   `json:"id"`  // the latest notification in the group
+	IDs        []primitive.ObjectID `json:"ids"` // every notification in the group
+	Type       string               `json:"type"`
+	Text       string               `json:"text"`
+	Link       string               `json:"link"`
+	Actors     []RenderedActor      `json:"actors"`
+	ActorCount int                  `json:"actor_count"`
+	Target     *RenderedTarget      `json:"target,omitempty"`
+	Seen       bool                 `json:"seen"`
+	CreatedAt  time.Time            `json:"created_at"`
+}
+
+// notificationTexts are the sentences per notification type; {actors} becomes
+// "Abebe", "Abebe and Sara" or "Abebe and 4 others"
+var notificationTexts = map[string]string{
+	"upvote_review":         "{actors} upvoted your review{of_book}",
+	"upvote_quote":          "{actors} upvoted your quote{from_book}",
+	"upvote_comment":        "{actors} liked your comment",
+	"comment_review":        "{actors} commented on your review{of_book}",
+	"comment_quote":         "{actors} commented on your quote{from_book}",
+	"reply":                 "{actors} replied to your comment",
+	"mention":               "{actors} mentioned you",
+	"new_review":            "{actors} submitted a review{of_book} for approval",
+	"edited_review":         "{actors} edited a review{of_book}, it needs approval again",
+	"book_borrowed":         "{actors} borrowed {name}",
+	"pending_registration":  "{count} new registration(s) waiting for approval",
+	"new_follower":          "{actors} started following you",
+	"content_hidden":        "Your {kind} was hidden after being reported",
+	"content_removed":       "Your {kind} was removed by a moderator",
+	"club_invite":           "{actors} invited you to join {name}",
+	"club_new_book":         "{actors} picked the next book for {name}",
+	"club_join_request":     "{actors} asked to join {name}",
+	"club_request_accepted": "Your request to join {name} was accepted",
+	"club_request_declined": "Your request to join {name} was declined",
+	"badge_awarded":         "You were awarded the {name} badge",
+	"badge_revoked":         "Your {name} badge was revoked",
+	"class_tag_promoted":    "You were promoted to {name}",
+	"loan_due_soon":         "{name} is due back soon",
+	"loan_due_today":        "{name} is due back today",
+	"loan_overdue":          "{name} is overdue, please return it",
+	"loan_overdue_admin":    "{actors} still hasn't returned {name}, it is long overdue",
+}
+
+// notificationTargets are the kinds of thing a type's target id can point at, tried in order
+var notificationTargets = map[string][]string{
+	"upvote_review":         {"review"},
+	"comment_review":        {"review"},
+	"new_review":            {"review"},
+	"edited_review":         {"review"},
+	"upvote_quote":          {"quote"},
+	"comment_quote":         {"quote"},
+	"upvote_comment":        {"review_comment", "quote_comment"},
+	"reply":                 {"review_comment", "quote_comment"},
+	"mention":               {"review_comment", "quote_comment", "club_post"},
+	"content_hidden":        {"review", "quote", "review_comment", "quote_comment"},
+	"content_removed":       {"review", "quote", "review_comment", "quote_comment", "report"},
+	"book_borrowed":         {"book"},
+	"new_follower":          {"user"},
+	"class_tag_promoted":    {"class_tag"},
+	"club_invite":           {"club_invite"},
+	"club_join_request":     {"club_invite"},
+	"club_new_book":         {"club"},
+	"club_request_accepted": {"club"},
+	"club_request_declined": {"club"},
+	"badge_awarded":         {"badge"},
+	"badge_revoked":         {"badge_audit"},
+	"loan_due_soon":         {"book"},
+	"loan_due_today":        {"book"},
+	"loan_overdue":          {"book"},
+	"loan_overdue_admin":    {"book"},
+}
+
+// notificationLinks are the deep links of types that don't link to their target
+var notificationLinks = map[string]string{
+	"pending_registration": "/admin/registrations",
+	"new_review":           "/admin/pending-reviews",
+	"edited_review":        "/admin/pending-reviews",
+	"badge_awarded":        "/badges",
+	"badge_revoked":        "/badges",
+	"digest":               "/notifications",
+	"loan_due_soon":        "/borrow-history",
+	"loan_due_today":       "/borrow-history",
+	"loan_overdue":         "/borrow-history",
+}
+
+// groupKey decides which notifications collapse into one entry; "" means never grouped
+func groupKey(n models.Notification) string {
+	switch n.Type {
+	case "upvote_review", "upvote_quote", "upvote_comment", "comment_review", "comment_quote":
+		return n.Type + ":" + n.TargetID.Hex()
+	case "new_follower", "pending_registration":
+		return n.Type
+	}
+	return ""
+}
+
+// digestLines are the counts in a digest, e.g. "2 new follower", "5 upvote review"
+func digestLines(summary map[string]int) []string {
+	types := make([]string, 0, len(summary))
+	for t := range summary {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	lines := make([]string, 0, len(types))
+	for _, t := range types {
+		lines = append(lines, strconv.Itoa(summary[t])+" "+strings.ReplaceAll(t, "_", " "))
+	}
+	return lines
+}
+
+// digestText sums up a digest, e.g. "Your daily digest: 2 new follower, 5 upvote review"
+func digestText(summary map[string]int) string {
+	return "Your daily digest: " + strings.Join(digestLines(summary), ", ")
+}
+
+func snippet(text string) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= 80 {
+		return string(runes)
+	}
+	return string(runes[:80]) + "…"
+}
+
+// notificationRenderer caches lookups while a page of notifications is rendered
+type notificationRenderer struct {
+	db     *mongo.Database
+	users  map[primitive.ObjectID]models.User
+	books  map[primitive.ObjectID]models.Book
+	target map[primitive.ObjectID]*RenderedTarget
+}
+
+func newNotificationRenderer(db *mongo.Database) *notificationRenderer {
+	return &notificationRenderer{
+		db:     db,
+		users:  make(map[primitive.ObjectID]models.User),
+		books:  make(map[primitive.ObjectID]models.Book),
+		target: make(map[primitive.ObjectID]*RenderedTarget),
+	}
+}
+
+func (nr *notificationRenderer) user(id primitive.ObjectID) (models.User, bool) {
+	if u, ok := nr.users[id]; ok {
+		return u, !u.ID.IsZero()
+	}
+	var u models.User
+	_ = nr.db.Collection("users").FindOne(context.Background(), bson.M{"_id": id}).Decode(&u)
+	nr.users[id] = u
+	return u, !u.ID.IsZero()
+}
+
+func (nr *notificationRenderer) book(id primitive.ObjectID) models.Book {
+	if b, ok := nr.books[id]; ok {
+		return b
+	}
+	var b models.Book
+	if !id.IsZero() {
+		_ = nr.db.Collection("books").FindOne(context.Background(), bson.M{"_id": id}).Decode(&b)
+	}
+	nr.books[id] = b
+	return b
+}
+
+func (nr *notificationRenderer) find(collection string, filter bson.M, out any) bool {
+	return nr.db.Collection(collection).FindOne(context.Background(), filter).Decode(out) == nil
+}
+
+// resolve looks the target id up as each kind in turn
+func (nr *notificationRenderer) resolve(id primitive.ObjectID, kinds []string) *RenderedTarget {
+	if id.IsZero() || len(kinds) == 0 {
+		return nil
+	}
+	if t, ok := nr.target[id]; ok {
+		return t
+	}
+
+	var t *RenderedTarget
+	for _, kind := range kinds {
+		if t = nr.resolveAs(id, kind); t != nil {
+			break
+		}
+	}
+	nr.target[id] = t
+	return t
+}
+
+func (nr *notificationRenderer) withBook(t *RenderedTarget, bookID primitive.ObjectID) *RenderedTarget {
+	b := nr.book(bookID)
+	t.BookTitle, t.ISBN = b.Title, b.ISBN
+	return t
+}
+
+func (nr *notificationRenderer) resolveAs(id primitive.ObjectID, kind string) *RenderedTarget {
+	byID := bson.M{"_id": id}
+	switch kind {
+	case "review":
+		var r models.Review
+		if nr.find("Reviews", byID, &r) {
+			return nr.withBook(&RenderedTarget{Kind: kind, ID: id, Snippet: snippet(r.ReviewText), Link: "/reviews/" + id.Hex()}, r.BookID)
+		}
+	case "quote":
+		var q models.Quote
+		if nr.find("Quotes", byID, &q) {
+			return nr.withBook(&RenderedTarget{Kind: kind, ID: id, Snippet: snippet(q.Text), Link: "/quotes/" + id.Hex()}, q.BookID)
+		}
+	case "review_comment":
+		var c models.ReviewComment
+		if nr.find("ReviewComments", byID, &c) {
+			t := &RenderedTarget{Kind: kind, ID: id, Snippet: snippet(c.Text), Link: "/reviews/" + c.ReviewID.Hex() + "#comment-" + id.Hex()}
+			var r models.Review
+			if nr.find("Reviews", bson.M{"_id": c.ReviewID}, &r) {
+				nr.withBook(t, r.BookID)
+			}
+			return t
+		}
+	case "quote_comment":
+		var c models.QuoteComment
+		if nr.find("QuoteComments", byID, &c) {
+			t := &RenderedTarget{Kind: kind, ID: id, Snippet: snippet(c.Text), Link: "/quotes/" + c.QuoteID.Hex() + "#comment-" + id.Hex()}
+			var q models.Quote
+			if nr.find("Quotes", bson.M{"_id": c.QuoteID}, &q) {
+				nr.withBook(t, q.BookID)
+			}
+			return t
+		}
+	case "club_post":
+		var p models.ClubPost
+		if nr.find("ClubPosts", byID, &p) {
+			return &RenderedTarget{Kind: kind, ID: id, Snippet: snippet(p.Text),
+				Link: "/clubs/" + p.ClubID.Hex() + "/milestones/" + p.MilestoneID.Hex() + "#post-" + id.Hex()}
+		}
+	case "report":
+		var r models.Report
+		if nr.find("Reports", byID, &r) {
+			return &RenderedTarget{Kind: r.ContentType, ID: r.ContentID}
+		}
+	case "book":
+		b := nr.book(id)
+		if !b.ID.IsZero() {
+			return &RenderedTarget{Kind: kind, ID: id, Name: b.Title, BookTitle: b.Title, ISBN: b.ISBN, Link: "/books/" + b.ISBN}
+		}
+	case "user":
+		if u, ok := nr.user(id); ok {
+			return &RenderedTarget{Kind: kind, ID: id, Name: u.Name, Link: "/users/" + u.ReaderID}
+		}
+	case "class_tag":
+		var c models.ClassTagChange
+		if nr.db.Collection("ClassTagHistory").FindOne(context.Background(), bson.M{"user_id": id, "promoted": true},
+			options.FindOne().SetSort(bson.M{"created_at": -1})).Decode(&c) == nil {
+			return &RenderedTarget{Kind: kind, ID: id, Name: c.To, Link: "/class-tag-history"}
+		}
+	case "club":
+		var c models.Club
+		if nr.find("Clubs", byID, &c) {
+			return &RenderedTarget{Kind: kind, ID: id, Name: c.Name, Link: "/clubs/" + id.Hex()}
+		}
+	case "club_invite":
+		var inv models.ClubInvite
+		if nr.find("ClubInvites", byID, &inv) {
+			var c models.Club
+			nr.find("Clubs", bson.M{"_id": inv.ClubID}, &c)
+			return &RenderedTarget{Kind: kind, ID: id, Name: c.Name, Link: "/clubs/" + inv.ClubID.Hex()}
+		}
+	case "badge":
+		var b models.Badge
+		if nr.find("Badges", byID, &b) {
+			return &RenderedTarget{Kind: kind, ID: id, Name: b.Name}
+		}
+	case "badge_audit":
+		var a models.BadgeAudit
+		if nr.find("BadgeAudits", bson.M{"badge_id": id}, &a) {
+			return &RenderedTarget{Kind: "badge", ID: id, Name: a.BadgeName}
+		}
+	}
+	return nil
+}
+
+func actorsPhrase(actors []RenderedActor, count int) string {
+	if count == 0 || len(actors) == 0 {
+		return "Someone"
+	}
+	switch count {
+	case 1:
+		return actors[0].Name
+	case 2:
+		if len(actors) > 1 {
+			return actors[0].Name + " and " + actors[1].Name
+		}
+	}
+	return actors[0].Name + " and " + strconv.Itoa(count-1) + " others"
+}
+
+// render builds one entry from a group of notifications, newest first
+func (nr *notificationRenderer) render(group []models.Notification) RenderedNotification {
+	latest := group[0]
+	out := RenderedNotification{
+		ID:        latest.ID,
+		Type:      latest.Type,
+		Seen:      true,
+		CreatedAt: latest.CreatedAt,
+		Actors:    []RenderedActor{},
+	}
+
+	seenActors := make(map[primitive.ObjectID]bool)
+	for _, n := range group {
+		out.IDs = append(out.IDs, n.ID)
+		if !n.Seen {
+			out.Seen = false
+		}
+		if n.ActorID.IsZero() || seenActors[n.ActorID] {
+			continue
+		}
+		seenActors[n.ActorID] = true
+		out.ActorCount++
+		if len(out.Actors) < 3 {
+			if u, ok := nr.user(n.ActorID); ok {
+				out.Actors = append(out.Actors, RenderedActor{ID: u.ID, Name: u.Name, ReaderID: u.ReaderID})
+			}
+		}
+	}
+
+	out.Target = nr.resolve(latest.TargetID, notificationTargets[latest.Type])
+	if latest.Type == "new_follower" {
+		// the target is the follower, the link goes to the newest one's profile
+		if len(out.Actors) > 0 {
+			out.Link = "/users/" + out.Actors[0].ReaderID
+		}
+		out.Target = nil
+	}
+
+	var name, kind, ofBook, fromBook string
+	if out.Target != nil {
+		name, kind = out.Target.Name, strings.ReplaceAll(out.Target.Kind, "_", " ")
+		if out.Target.BookTitle != "" {
+			ofBook, fromBook = " of "+out.Target.BookTitle, " from "+out.Target.BookTitle
+		}
+		if out.Link == "" {
+			out.Link = out.Target.Link
+		}
+	}
+	if link, ok := notificationLinks[latest.Type]; ok {
+		out.Link = link
+	}
+	if kind == "" {
+		kind = "post"
+	}
+
+	text, ok := notificationTexts[latest.Type]
+	if latest.Type == "digest" {
+		text, ok = digestText(latest.Summary), true
+	}
+	if !ok {
+		text = "{actors}: " + strings.ReplaceAll(latest.Type, "_", " ")
+	}
+	out.Text = strings.NewReplacer(
+		"{actors}", actorsPhrase(out.Actors, out.ActorCount),
+		"{count}", strconv.Itoa(len(group)),
+		"{name}", name,
+		"{kind}", kind,
+		"{of_book}", ofBook,
+		"{from_book}", fromBook,
+	).Replace(text)
+	return out
+}
+
+// RenderNotification renders a single notification, e.g. for the live stream
+func RenderNotification(db *mongo.Database, n models.Notification) RenderedNotification {
+	return newNotificationRenderer(db).render([]models.Notification{n})
+}
+
+// RenderNotifications groups notifications (given newest first) and renders the
+// page-th page of limit groups. It also returns how many groups there are
+func RenderNotifications(db *mongo.Database, notifications []models.Notification, group bool, page, limit int) ([]RenderedNotification, int) {
+	var groups [][]models.Notification
+	index := make(map[string]int)
+	for _, n := range notifications {
+		key := ""
+		if group {
+			key = groupKey(n)
+		}
+		if i, ok := index[key]; ok && key != "" {
+			groups[i] = append(groups[i], n)
+			continue
+		}
+		if key != "" {
+			index[key] = len(groups)
+		}
+		groups = append(groups, []models.Notification{n})
+	}
+
+	total := len(groups)
+	start := (page - 1) * limit
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+
+	nr := newNotificationRenderer(db)
+	rendered := []RenderedNotification{}
+	for _, g := range groups[start:end] {
+		rendered = append(rendered, nr.render(g))
+	}
+	return rendered, total
+}
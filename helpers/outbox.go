@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+// how long a claimed email is left alone before another worker may retry it,
+// so a send cut off by a restart is picked up again
+const outboxLease = 5 * time.Minute
+
+// QueueEmail renders the named template and puts it in the outbox; it is sent
+// by the outbox worker so a slow or down mail server never blocks a request
+func QueueEmail(db *mongo.Database, to, template string, data map[string]any) error {
+	if to == "" {
+		return nil
+	}
+	msg, err := RenderEmail(template, to, data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = db.Collection("EmailOutbox").InsertOne(context.Background(), models.OutboxEmail{
+		To:            msg.To,
+		Template:      template,
+		Subject:       msg.Subject,
+		Text:          msg.Text,
+		HTML:          msg.HTML,
+		Status:        "pending",
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	return err
+}
+
+// outboxRetention is how long sent and failed emails are kept, OUTBOX_RETENTION_DAYS (default 30)
+func outboxRetention() time.Duration {
+	days := 30
+	if n, err := strconv.Atoi(os.Getenv("OUTBOX_RETENTION_DAYS")); err == nil && n > 0 {
+		days = n
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// StartOutbox sends due emails from the outbox every few seconds. A failed send
+// is retried with exponential backoff up to MAIL_MAX_ATTEMPTS (default 8) times
+func StartOutbox(db *mongo.Database, mailer Mailer) {
+	maxAttempts := 8
+	if n, err := strconv.Atoi(os.Getenv("MAIL_MAX_ATTEMPTS")); err == nil && n > 0 {
+		maxAttempts = n
+	}
+
+	// sent and failed emails are removed by MongoDB once their expire_at passes
+	_, err := db.Collection("EmailOutbox").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.M{"expire_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("outbox: could not create the TTL index: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			for sendNextEmail(db, mailer, maxAttempts) {
+			}
+		}
+	}()
+}
+
+// sendNextEmail claims one due email and tries to send it; false when nothing is due
+// maxRetryDelay caps the backoff, which a large MAIL_MAX_ATTEMPTS would otherwise
+// push out for years or overflow into the past
+const maxRetryDelay = 24 * time.Hour
+
+// retryDelay doubles base for every attempt after the first, up to maxRetryDelay
+func retryDelay(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+func sendNextEmail(db *mongo.Database, mailer Mailer, maxAttempts int) bool {
+	outboxCol := db.Collection("EmailOutbox")
+	now := time.Now()
+
+	var email models.OutboxEmail
+	err := outboxCol.FindOneAndUpdate(context.Background(),
+		bson.M{"status": "pending", "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(outboxLease)}, "$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetSort(bson.M{"next_attempt_at": 1}).SetReturnDocument(options.After),
+	).Decode(&email)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("outbox: %v", err)
+		}
+		return false
+	}
+
+	msg := Email{To: email.To, Subject: email.Subject, Text: email.Text, HTML: email.HTML}
+	if !email.ResetID.IsZero() {
+		msg, err = resetEmail(db, email)
+		if err == ErrInvalidResetToken {
+			// the reset was used, replaced or expired before the email went out
+			expire := time.Now().Add(outboxRetention())
+			_, _ = outboxCol.UpdateByID(context.Background(), email.ID, bson.M{"$set": bson.M{"status": "failed", "last_error": err.Error(), "expire_at": expire}})
+			return true
+		}
+	}
+	if err == nil {
+		err = mailer.Send(msg)
+	}
+	if err == nil {
+		_, _ = outboxCol.UpdateByID(context.Background(), email.ID, bson.M{
+			"$set":   bson.M{"status": "sent", "sent_at": time.Now(), "expire_at": time.Now().Add(outboxRetention())},
+			"$unset": bson.M{"last_error": ""},
+		})
+		return true
+	}
+
+	update := bson.M{"last_error": err.Error()}
+	if email.Attempts >= maxAttempts {
+		update["status"] = "failed"
+		update["expire_at"] = time.Now().Add(outboxRetention())
+		log.Printf("outbox: giving up on %s email to %s: %v", email.Template, email.To, err)
+	} else {
+		update["next_attempt_at"] = time.Now().Add(retryDelay(time.Minute, email.Attempts))
+	}
+	_, _ = outboxCol.UpdateByID(context.Background(), email.ID, bson.M{"$set": update})
+	return true
+}
@@ -0,0 +1,28 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{"first retry", time.Minute, 1, time.Minute},
+		{"doubles", time.Minute, 4, 8 * time.Minute},
+		{"capped", time.Minute, 20, maxRetryDelay},
+		{"shift would overflow", time.Minute, 100, maxRetryDelay},
+		{"webhook base", 30 * time.Second, 3, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryDelay(tt.base, tt.attempts); got != tt.want {
+				t.Errorf("retryDelay(%v, %d) = %v, want %v", tt.base, tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
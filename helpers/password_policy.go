@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// PasswordPolicy is what every new password has to satisfy
+type PasswordPolicy struct {
+	MinLength int      `json:"min_length"`
+	Classes   []string `json:"classes"` // any of letter, lower, upper, digit, symbol
+}
+
+var passwordClasses = map[string]func(rune) bool{
+	"letter": unicode.IsLetter,
+	"lower":  unicode.IsLower,
+	"upper":  unicode.IsUpper,
+	"digit":  unicode.IsDigit,
+	"symbol": func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r) },
+}
+
+// CurrentPasswordPolicy reads PASSWORD_MIN_LENGTH (default 8) and PASSWORD_CLASSES
+// (default "letter,digit")
+func CurrentPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{MinLength: 8}
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && n > 0 {
+		policy.MinLength = n
+	}
+	classes := os.Getenv("PASSWORD_CLASSES")
+	if classes == "" {
+		classes = "letter,digit"
+	}
+	for _, c := range strings.Split(classes, ",") {
+		c = strings.TrimSpace(strings.ToLower(c))
+		if _, ok := passwordClasses[c]; ok {
+			policy.Classes = append(policy.Classes, c)
+		}
+	}
+	return policy
+}
+
+var (
+	breachedOnce   sync.Once
+	breachedHashes map[string]bool
+)
+
+// loadBreachedPasswords reads PASSWORD_BREACHED_LIST once. Each line is a password or
+// the SHA-1 of one in hex, optionally followed by ":count" as in the Pwned Passwords files
+func loadBreachedPasswords() {
+	breachedHashes = make(map[string]bool)
+	path := os.Getenv("PASSWORD_BREACHED_LIST")
+	if path == "" {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("could not read breached password list: %v", err)
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); len(hash) == 40 {
+			if _, err := hex.DecodeString(hash); err == nil {
+				breachedHashes[strings.ToUpper(hash)] = true
+				continue
+			}
+		}
+		breachedHashes[sha1Hex(line)] = true
+	}
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// ValidatePassword checks a new password against the policy, the breached list and
+// the account's email
+func ValidatePassword(password, email string) error {
+	policy := CurrentPasswordPolicy()
+	if len([]rune(password)) < policy.MinLength {
+		return fmt.Errorf("password must be at least %d characters", policy.MinLength)
+	}
+	for _, class := range policy.Classes {
+		if !strings.ContainsFunc(password, passwordClasses[class]) {
+			return fmt.Errorf("password must contain a %s character", class)
+		}
+	}
+	if email != "" {
+		local, _, _ := strings.Cut(email, "@")
+		if strings.EqualFold(password, email) || strings.EqualFold(password, local) {
+			return errors.New("password cannot be your email")
+		}
+	}
+
+	breachedOnce.Do(loadBreachedPasswords)
+	if breachedHashes[sha1Hex(password)] {
+		return errors.New("password appears in a list of breached passwords, pick another one")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	content := "password123\n" + sha1Hex("letmein2024") + ":12\n"
+	if err := os.WriteFile(list, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PASSWORD_BREACHED_LIST", list)
+	breachedOnce = sync.Once{}
+	t.Cleanup(func() { breachedOnce = sync.Once{} })
+
+	tests := []struct {
+		name      string
+		minLength string
+		classes   string
+		password  string
+		email     string
+		wantErr   string // part of the error, empty when the password is accepted
+	}{
+		{name: "default policy", password: "reader42x"},
+		{name: "too short", password: "abc1", wantErr: "at least 8"},
+		{name: "length counts runes", password: "ááááááá1"},
+		{name: "needs a digit", password: "abcdefgh", wantErr: "digit"},
+		{name: "needs a letter", password: "12345678", wantErr: "letter"},
+		{name: "email", password: "reader42@insa.et", email: "Reader42@insa.et", wantErr: "email"},
+		{name: "email local part", password: "Reader42", email: "reader42@insa.et", wantErr: "email"},
+		{name: "no email given", password: "reader42"},
+		{name: "breached plain line", password: "password123", wantErr: "breached"},
+		{name: "breached sha1 line", password: "letmein2024", wantErr: "breached"},
+		{name: "longer minimum", minLength: "12", password: "reader42x", wantErr: "at least 12"},
+		{name: "invalid minimum uses default", minLength: "-3", password: "reader42x"},
+		{name: "upper required", classes: "lower,upper,digit", password: "reader42x", wantErr: "upper"},
+		{name: "symbol required", classes: "letter,symbol", password: "reader42x", wantErr: "symbol"},
+		{name: "symbol given", classes: "letter,symbol", password: "reader 42x"},
+		{name: "unknown classes ignored", classes: "emoji, LOWER", password: "readerxyz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PASSWORD_MIN_LENGTH", tt.minLength)
+			t.Setenv("PASSWORD_CLASSES", tt.classes)
+
+			err := ValidatePassword(tt.password, tt.email)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidatePassword(%q) = %v, want nil", tt.password, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidatePassword(%q) = %v, want an error about %q", tt.password, err, tt.wantErr)
+			}
+		})
+	}
+}
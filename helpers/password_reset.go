@@ -0,0 +1,137 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+var ErrInvalidResetToken = errors.New("reset token is invalid, used or expired")
+
+// passwordResetTTL is how long a reset link works, PASSWORD_RESET_TTL_MINUTES (default 30)
+func passwordResetTTL() time.Duration {
+	minutes := 30
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TTL_MINUTES")); err == nil && n > 0 {
+		minutes = n
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssuePasswordReset replaces any open reset of the user with a new one and queues
+// the email; the token is only made when the email is sent (see resetEmail)
+func IssuePasswordReset(db *mongo.Database, user models.User, reason string) error {
+	resetsCol := db.Collection("PasswordResets")
+	now := time.Now()
+	_, _ = resetsCol.UpdateMany(context.Background(),
+		bson.M{"user_id": user.ID, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": now}},
+	)
+	ttl := passwordResetTTL()
+	res, err := resetsCol.InsertOne(context.Background(), models.PasswordResetToken{
+		UserID:    user.ID,
+		Reason:    reason,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("EmailOutbox").InsertOne(context.Background(), models.OutboxEmail{
+		To:       user.Email,
+		Template: MailPasswordReset,
+		Subject:  mailTemplates[MailPasswordReset].Subject,
+		Data: map[string]string{
+			"Name":      user.Name,
+			"ExpiresIn": strconv.Itoa(int(ttl.Minutes())) + " minutes",
+		},
+		ResetID:       res.InsertedID.(primitive.ObjectID),
+		Status:        "pending",
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	return err
+}
+
+// resetEmail renders a queued reset email with a fresh token. Only the token's hash
+// is saved on the reset, so neither the outbox nor the resets hold a usable token.
+// A retried send replaces the token of the attempt before
+func resetEmail(db *mongo.Database, email models.OutboxEmail) (Email, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return Email{}, err
+	}
+	token := hex.EncodeToString(b)
+
+	res, err := db.Collection("PasswordResets").UpdateOne(context.Background(),
+		bson.M{"_id": email.ResetID, "used_at": bson.M{"$exists": false}, "expires_at": bson.M{"$gt": time.Now()}},
+		bson.M{"$set": bson.M{"token_hash": hashResetToken(token)}},
+	)
+	if err != nil {
+		return Email{}, err
+	}
+	if res.MatchedCount == 0 {
+		return Email{}, ErrInvalidResetToken
+	}
+
+	data := map[string]any{"Token": token}
+	for k, v := range email.Data {
+		data[k] = v
+	}
+	return RenderEmail(email.Template, email.To, data)
+}
+
+// PasswordResetUser is whose an unused, unexpired reset token is, without using it up
+func PasswordResetUser(db *mongo.Database, token string) (models.User, error) {
+	var reset models.PasswordResetToken
+	err := db.Collection("PasswordResets").FindOne(context.Background(), bson.M{
+		"token_hash": hashResetToken(token), "used_at": bson.M{"$exists": false}, "expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&reset)
+	if err == mongo.ErrNoDocuments {
+		return models.User{}, ErrInvalidResetToken
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	var user models.User
+	err = db.Collection("users").FindOne(context.Background(), bson.M{"_id": reset.UserID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return models.User{}, ErrInvalidResetToken
+	}
+	return user, err
+}
+
+// ConsumePasswordReset uses up a reset token and returns whose it was
+func ConsumePasswordReset(db *mongo.Database, token string) (primitive.ObjectID, error) {
+	var reset models.PasswordResetToken
+	now := time.Now()
+	err := db.Collection("PasswordResets").FindOneAndUpdate(context.Background(),
+		bson.M{"token_hash": hashResetToken(token), "used_at": bson.M{"$exists": false}, "expires_at": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"used_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&reset)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, ErrInvalidResetToken
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return reset.UserID, nil
+}
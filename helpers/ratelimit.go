@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var rateLimitIndexOnce sync.Once
+
+// AllowRequest counts a request against key in fixed windows of the given length and
+// reports whether it is within limit. Counters live in MongoDB so every instance
+// shares them; a TTL index removes old windows
+func AllowRequest(db *mongo.Database, key string, limit int, window time.Duration) (bool, error) {
+	col := db.Collection("RateLimits")
+	rateLimitIndexOnce.Do(func() {
+		_, _ = col.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys:    bson.M{"expires_at": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+	})
+
+	start := time.Now().Truncate(window)
+	var counter struct {
+		Count int `bson:"count"`
+	}
+	err := col.FindOneAndUpdate(context.Background(),
+		bson.M{"_id": key + ":" + strconv.FormatInt(start.Unix(), 10)},
+		bson.M{"$inc": bson.M{"count": 1}, "$setOnInsert": bson.M{"expires_at": start.Add(window)}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return false, err
+	}
+	return counter.Count <= limit, nil
+}
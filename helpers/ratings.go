@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ValidateRating checks a star rating is between 1 and 5 in half-star steps
+func ValidateRating(rating float64) error {
+	if rating < 1 || rating > 5 {
+		return errors.New("rating must be between 1 and 5")
+	}
+	if rating*2 != math.Trunc(rating*2) {
+		return errors.New("rating must be a whole or half star")
+	}
+	return nil
+}
+
+// AddBookRating counts an approved review's rating towards the book's aggregates
+func AddBookRating(db *mongo.Database, bookID primitive.ObjectID, rating float64) error {
+	return applyBookRating(db, bookID, rating, 1)
+}
+
+// RemoveBookRating takes a rating back out when its review is no longer public
+func RemoveBookRating(db *mongo.Database, bookID primitive.ObjectID, rating float64) error {
+	return applyBookRating(db, bookID, rating, -1)
+}
+
+func applyBookRating(db *mongo.Database, bookID primitive.ObjectID, rating float64, sign int) error {
+	if rating == 0 {
+		return nil
+	}
+	booksCol := db.Collection("books")
+
+	bucket := strconv.Itoa(int(math.Floor(rating)))
+	_, err := booksCol.UpdateOne(context.Background(), bson.M{"_id": bookID}, bson.M{
+		"$inc": bson.M{
+			"rating_sum":                     rating * float64(sign),
+			"rating_count":                   sign,
+			"rating_distribution." + bucket: sign,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// recompute the average from the stored totals so it never drifts
+	_, err = booksCol.UpdateOne(context.Background(), bson.M{"_id": bookID}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"rating_average": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$rating_count", 0}},
+				bson.M{"$round": bson.A{bson.M{"$divide": bson.A{"$rating_sum", "$rating_count"}}, 2}},
+				0,
+			}},
+		}}},
+	})
+	return err
+}
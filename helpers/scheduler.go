@@ -0,0 +1,255 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// how long a job may hold its lock; a crashed instance's lock frees itself after this
+const jobLease = time.Hour
+
+// JobFunc does the work of a job and returns a short summary for the run history
+type JobFunc func(db *mongo.Database) (string, error)
+
+// Job is a task run on a cron schedule
+type Job struct {
+	Name        string
+	Spec        string // "" when the job only runs when triggered
+	Description string
+	Run         JobFunc
+	schedule    *CronSchedule
+}
+
+// JobStatus is a job as the admin sees it
+type JobStatus struct {
+	Name        string         `json:"name"`
+	Spec        string         `json:"spec"`
+	Description string         `json:"description"`
+	NextRun     *time.Time     `json:"next_run,omitempty"`
+	Running     bool           `json:"running"`
+	LastRun     *models.JobRun `json:"last_run,omitempty"`
+}
+
+// Scheduler runs jobs in the background. Every instance runs a scheduler, a lock in
+// the JobLocks collection makes sure only one of them runs a job for a given minute
+type Scheduler struct {
+	db       *mongo.Database
+	instance string
+
+	mu   sync.Mutex
+	jobs []*Job
+}
+
+func NewScheduler(db *mongo.Database) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{db: db, instance: fmt.Sprintf("%s-%d", host, os.Getpid())}
+}
+
+// Register adds a job. JOB_SCHEDULE_<NAME> (e.g. JOB_SCHEDULE_CLASS_TAGS) replaces the
+// spec, "off" leaves the job to manual triggers only
+func (s *Scheduler) Register(name, spec, description string, run JobFunc) error {
+	envName := "JOB_SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	if override := os.Getenv(envName); override != "" {
+		spec = override
+	}
+	if spec == "off" {
+		spec = ""
+	}
+
+	job := &Job{Name: name, Spec: spec, Description: description, Run: run}
+	if spec != "" {
+		schedule, err := ParseCron(spec)
+		if err != nil {
+			return fmt.Errorf("job %s: %v", name, err)
+		}
+		job.schedule = schedule
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.Name == name {
+			return fmt.Errorf("job %s is registered twice", name)
+		}
+	}
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+func (s *Scheduler) job(name string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.Name == name {
+			return j
+		}
+	}
+	return nil
+}
+
+// Start checks at the start of every minute which jobs are due
+func (s *Scheduler) Start() {
+	go func() {
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			time.Sleep(next.Sub(now))
+
+			s.mu.Lock()
+			jobs := append([]*Job(nil), s.jobs...)
+			s.mu.Unlock()
+			for _, job := range jobs {
+				if job.schedule != nil && job.schedule.matches(next) {
+					slot := next
+					go func(job *Job) {
+						if _, err := s.start(job, "schedule", &slot); err != nil && err != ErrJobRunning {
+							log.Printf("job %s: %v", job.Name, err)
+						}
+					}(job)
+				}
+			}
+		}
+	}()
+}
+
+// RunNow triggers a job by hand; it runs in the background and the returned run
+// can be followed in the history
+func (s *Scheduler) RunNow(name string) (models.JobRun, error) {
+	job := s.job(name)
+	if job == nil {
+		return models.JobRun{}, ErrUnknownJob
+	}
+	return s.start(job, "manual", nil)
+}
+
+// start takes the job's lock and runs it. A scheduled run passes its slot so that no
+// other instance runs the same slot again after this one released the lock
+func (s *Scheduler) start(job *Job, trigger string, slot *time.Time) (models.JobRun, error) {
+	if err := s.lock(job.Name, slot); err != nil {
+		return models.JobRun{}, err
+	}
+
+	run := models.JobRun{
+		Job:       job.Name,
+		Trigger:   trigger,
+		Instance:  s.instance,
+		Status:    "running",
+		StartedAt: time.Now(),
+	}
+	res, err := s.db.Collection("JobRuns").InsertOne(context.Background(), run)
+	if err != nil {
+		s.unlock(job.Name)
+		return models.JobRun{}, err
+	}
+	run.ID = res.InsertedID.(primitive.ObjectID)
+
+	go s.execute(job, run)
+	return run, nil
+}
+
+func (s *Scheduler) execute(job *Job, run models.JobRun) {
+	defer s.unlock(job.Name)
+
+	result, err := func() (result string, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic: %v", p)
+			}
+		}()
+		return job.Run(s.db)
+	}()
+
+	finished := time.Now()
+	update := bson.M{
+		"status":      "success",
+		"result":      result,
+		"finished_at": finished,
+		"duration_ms": finished.Sub(run.StartedAt).Milliseconds(),
+	}
+	if err != nil {
+		update["status"] = "failed"
+		update["error"] = err.Error()
+		log.Printf("job %s failed: %v", job.Name, err)
+	}
+	_, _ = s.db.Collection("JobRuns").UpdateByID(context.Background(), run.ID, bson.M{"$set": update})
+}
+
+func (s *Scheduler) lock(name string, slot *time.Time) error {
+	now := time.Now()
+	filter := bson.M{"_id": name, "locked_until": bson.M{"$lt": now}}
+	set := bson.M{"owner": s.instance, "locked_until": now.Add(jobLease)}
+	if slot != nil {
+		filter["$or"] = bson.A{bson.M{"last_slot": bson.M{"$exists": false}}, bson.M{"last_slot": bson.M{"$lt": *slot}}}
+		set["last_slot"] = *slot
+	}
+
+	// when the lock is held the filter misses and the upsert hits the existing _id
+	_, err := s.db.Collection("JobLocks").UpdateOne(context.Background(), filter, bson.M{"$set": set}, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrJobRunning
+	}
+	return err
+}
+
+func (s *Scheduler) unlock(name string) {
+	_, _ = s.db.Collection("JobLocks").UpdateOne(context.Background(),
+		bson.M{"_id": name, "owner": s.instance},
+		bson.M{"$set": bson.M{"locked_until": time.Now()}},
+	)
+}
+
+// Jobs lists the registered jobs with their next and last run
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	jobs := append([]*Job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		status := JobStatus{Name: job.Name, Spec: job.Spec, Description: job.Description}
+		if job.schedule != nil {
+			if next := job.schedule.Next(now); !next.IsZero() {
+				status.NextRun = &next
+			}
+		}
+
+		var lock struct {
+			LockedUntil time.Time `bson:"locked_until"`
+		}
+		if err := s.db.Collection("JobLocks").FindOne(context.Background(), bson.M{"_id": job.Name}).Decode(&lock); err == nil {
+			status.Running = lock.LockedUntil.After(now)
+		}
+		var last models.JobRun
+		err := s.db.Collection("JobRuns").FindOne(context.Background(), bson.M{"job": job.Name},
+			options.FindOne().SetSort(bson.M{"started_at": -1})).Decode(&last)
+		if err == nil {
+			status.LastRun = &last
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// HasJob reports whether a job with that name is registered
+func (s *Scheduler) HasJob(name string) bool {
+	return s.job(name) != nil
+}
@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func noopJob(*mongo.Database) (string, error) { return "", nil }
+
+func TestSchedulerRegister(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		spec     string
+		wantErr  bool
+		wantSpec string
+	}{
+		{name: "daily", spec: "@daily", wantSpec: "@daily"},
+		{name: "bad spec", spec: "0 25 * * *", wantErr: true},
+		{name: "manual only", spec: "", wantSpec: ""},
+		{name: "env override", env: "*/5 * * * *", spec: "@daily", wantSpec: "*/5 * * * *"},
+		{name: "env off", env: "off", spec: "@daily", wantSpec: ""},
+		{name: "bad env override", env: "nope", spec: "@daily", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JOB_SCHEDULE_TEST_JOB", tt.env)
+			s := &Scheduler{}
+			err := s.Register("test-job", tt.spec, "", noopJob)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			job := s.job("test-job")
+			if job.Spec != tt.wantSpec {
+				t.Errorf("spec = %q, want %q", job.Spec, tt.wantSpec)
+			}
+			if (job.schedule != nil) != (tt.wantSpec != "") {
+				t.Errorf("schedule parsed = %v, want %v", job.schedule != nil, tt.wantSpec != "")
+			}
+		})
+	}
+
+	t.Run("twice", func(t *testing.T) {
+		s := &Scheduler{}
+		if err := s.Register("twice", "", "", noopJob); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Register("twice", "", "", noopJob); err == nil {
+			t.Fatal("registering a job name twice should fail")
+		}
+	})
+}
+
+func TestSchedulerLock(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	slot := time.Date(2026, 1, 1, 2, 30, 0, 0, time.UTC)
+	duplicate := mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"})
+	failed := mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Message: "bad value", Name: "BadValue"})
+
+	tests := []struct {
+		name     string
+		slot     *time.Time
+		response bson.D
+		want     error
+		wantAny  bool // any error other than ErrJobRunning
+	}{
+		{name: "free lock for a slot", slot: &slot, response: mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1})},
+		{name: "free lock by hand", response: mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1})},
+		{name: "held or slot already run", slot: &slot, response: duplicate, want: ErrJobRunning},
+		{name: "held when run by hand", response: duplicate, want: ErrJobRunning},
+		{name: "database error", slot: &slot, response: failed, wantAny: true},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(tt.response)
+			s := &Scheduler{db: mt.DB, instance: "test"}
+
+			err := s.lock("class-tags", tt.slot)
+			switch {
+			case tt.wantAny:
+				if err == nil || errors.Is(err, ErrJobRunning) {
+					mt.Fatalf("lock() = %v, want a database error", err)
+				}
+			case !errors.Is(err, tt.want):
+				mt.Fatalf("lock() = %v, want %v", err, tt.want)
+			}
+
+			update := mt.GetStartedEvent().Command.Lookup("updates", "0").Document()
+			if !update.Lookup("upsert").Boolean() {
+				mt.Error("the lock must be an upsert so the first run creates it")
+			}
+			_, hasSlotFilter := update.Lookup("q").Document().LookupErr("$or")
+			_, setsSlot := update.Lookup("u", "$set").Document().LookupErr("last_slot")
+			if wantSlot := tt.slot != nil; (hasSlotFilter == nil) != wantSlot || (setsSlot == nil) != wantSlot {
+				mt.Errorf("slot filter/set = %v/%v, want both %v", hasSlotFilter == nil, setsSlot == nil, wantSlot)
+			}
+			if tt.slot != nil {
+				got := update.Lookup("u", "$set", "last_slot").Time()
+				if !got.Equal(slot) {
+					mt.Errorf("last_slot = %v, want %v", got, slot)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerRunNow(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("unknown job", func(mt *mtest.T) {
+		s := &Scheduler{db: mt.DB, instance: "test"}
+		if _, err := s.RunNow("missing"); !errors.Is(err, ErrUnknownJob) {
+			mt.Fatalf("RunNow() = %v, want ErrUnknownJob", err)
+		}
+	})
+
+	mt.Run("a running job is not started twice", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"}))
+		ran := false
+		s := &Scheduler{db: mt.DB, instance: "test"}
+		if err := s.Register("busy", "", "", func(*mongo.Database) (string, error) { ran = true; return "", nil }); err != nil {
+			mt.Fatal(err)
+		}
+
+		if _, err := s.RunNow("busy"); !errors.Is(err, ErrJobRunning) {
+			mt.Fatalf("RunNow() = %v, want ErrJobRunning", err)
+		}
+		if events := mt.GetAllStartedEvents(); len(events) != 1 {
+			mt.Errorf("%d commands sent, want only the lock attempt and no run history", len(events))
+		}
+		if ran {
+			mt.Error("the job ran without its lock")
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+var ErrInvalidRefreshToken = errors.New("refresh token is invalid, expired or revoked")
+
+// refreshReuseGrace is how long after a rotation the token it replaced still works, so
+// two tabs refreshing at once aren't taken for a stolen token
+const refreshReuseGrace = 10 * time.Second
+
+// AccessTokenTTL is how long an access token works, ACCESS_TOKEN_MINUTES (default 15)
+func AccessTokenTTL() time.Duration {
+	minutes := 15
+	if n, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_MINUTES")); err == nil && n > 0 {
+		minutes = n
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// refreshTokenTTL is how long an unused session lasts, REFRESH_TOKEN_DAYS (default 30)
+func refreshTokenTTL() time.Duration {
+	days := 30
+	if n, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_DAYS")); err == nil && n > 0 {
+		days = n
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// newRefreshSecret returns a refresh token for the session ("<session id>.<secret>")
+// and the hash that is stored
+func newRefreshSecret(sessionID primitive.ObjectID) (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret := hex.EncodeToString(b)
+	return sessionID.Hex() + "." + secret, hashRefreshSecret(secret), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession starts a session for a user who just logged in and returns its refresh token
+func CreateSession(db *mongo.Database, user models.User, device, userAgent, ip string) (models.Session, string, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:           primitive.NewObjectID(),
+		UserID:       user.ID,
+		TokenVersion: user.TokenVersion,
+		Device:       device,
+		UserAgent:    userAgent,
+		IP:           ip,
+		CreatedAt:    now,
+		LastUsedAt:   now,
+		ExpiresAt:    now.Add(refreshTokenTTL()),
+	}
+	refreshToken, hash, err := newRefreshSecret(session.ID)
+	if err != nil {
+		return models.Session{}, "", err
+	}
+	session.RefreshHash = hash
+
+	if _, err := db.Collection("Sessions").InsertOne(context.Background(), session); err != nil {
+		return models.Session{}, "", err
+	}
+	return session, refreshToken, nil
+}
+
+// RotateSession swaps a refresh token for a new one and returns the session with its
+// (possibly changed) user. Presenting a token that was already rotated away means it
+// leaked, so the whole session is revoked, unless the rotation was only refreshReuseGrace ago
+func RotateSession(db *mongo.Database, refreshToken, ip string) (models.Session, models.User, string, error) {
+	idHex, secret, ok := strings.Cut(refreshToken, ".")
+	sessionID, err := primitive.ObjectIDFromHex(idHex)
+	if !ok || err != nil {
+		return models.Session{}, models.User{}, "", ErrInvalidRefreshToken
+	}
+	sessionsCol := db.Collection("Sessions")
+	hash := hashRefreshSecret(secret)
+
+	newToken, newHash, err := newRefreshSecret(sessionID)
+	if err != nil {
+		return models.Session{}, models.User{}, "", err
+	}
+	now := time.Now()
+	var session models.Session
+	err = sessionsCol.FindOneAndUpdate(context.Background(),
+		bson.M{"_id": sessionID, "refresh_hash": hash, "revoked_at": bson.M{"$exists": false}, "expires_at": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{
+			"refresh_hash":  newHash,
+			"previous_hash": hash,
+			"last_used_at":  now,
+			"ip":            ip,
+			"expires_at":    now.Add(refreshTokenTTL()),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		// the token was rotated a moment ago by a concurrent refresh: rotate once more but
+		// leave last_used_at alone so the window can't be stretched by reusing it
+		err = sessionsCol.FindOneAndUpdate(context.Background(),
+			bson.M{
+				"_id": sessionID, "previous_hash": hash, "revoked_at": bson.M{"$exists": false},
+				"expires_at": bson.M{"$gt": now}, "last_used_at": bson.M{"$gte": now.Add(-refreshReuseGrace)},
+			},
+			bson.M{"$set": bson.M{"refresh_hash": newHash, "ip": ip}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&session)
+	}
+	if err == mongo.ErrNoDocuments {
+		// an old token of a live session: someone else has the current one
+		_, _ = sessionsCol.UpdateOne(context.Background(),
+			bson.M{"_id": sessionID, "previous_hash": hash, "revoked_at": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"revoked_at": now}},
+		)
+		return models.Session{}, models.User{}, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return models.Session{}, models.User{}, "", err
+	}
+
+	var user models.User
+	err = db.Collection("users").FindOne(context.Background(), bson.M{"_id": session.UserID}).Decode(&user)
+	if err != nil || user.TokenVersion != session.TokenVersion {
+		_ = RevokeSession(db, session.UserID, session.ID)
+		return models.Session{}, models.User{}, "", ErrInvalidRefreshToken
+	}
+	return session, user, newToken, nil
+}
+
+// SessionActive reports whether an access token's session is still live and was
+// issued for the user's current token version
+func SessionActive(db *mongo.Database, userID, sessionID primitive.ObjectID, tokenVersion int) bool {
+	var user struct {
+		TokenVersion int `bson:"token_version"`
+	}
+	err := db.Collection("users").FindOne(context.Background(), bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"token_version": 1})).Decode(&user)
+	if err != nil || user.TokenVersion != tokenVersion {
+		return false
+	}
+	if sessionID.IsZero() {
+		return true
+	}
+	count, err := db.Collection("Sessions").CountDocuments(context.Background(), bson.M{
+		"_id": sessionID, "user_id": userID, "revoked_at": bson.M{"$exists": false}, "expires_at": bson.M{"$gt": time.Now()},
+	})
+	return err == nil && count == 1
+}
+
+// ActiveSessions lists the user's live sessions, most recently used first
+func ActiveSessions(db *mongo.Database, userID primitive.ObjectID) ([]models.Session, error) {
+	cursor, err := db.Collection("Sessions").Find(context.Background(),
+		bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}, "expires_at": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.M{"last_used_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	sessions := []models.Session{}
+	err = cursor.All(context.Background(), &sessions)
+	return sessions, err
+}
+
+// RevokeSession ends one session of a user; mongo.ErrNoDocuments when there is no such live session
+func RevokeSession(db *mongo.Database, userID, sessionID primitive.ObjectID) error {
+	res, err := db.Collection("Sessions").UpdateOne(context.Background(),
+		bson.M{"_id": sessionID, "user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RevokeOtherSessions ends every session of a user except keep (e.g. after a password change)
+func RevokeOtherSessions(db *mongo.Database, userID, keep primitive.ObjectID) error {
+	_, err := db.Collection("Sessions").UpdateMany(context.Background(),
+		bson.M{"user_id": userID, "_id": bson.M{"$ne": keep}, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	return err
+}
+
+// RevokeAllSessions bumps the user's token version, which invalidates every access
+// token already handed out, and ends all their sessions
+func RevokeAllSessions(db *mongo.Database, userID primitive.ObjectID) error {
+	_, err := db.Collection("users").UpdateOne(context.Background(), bson.M{"_id": userID}, bson.M{"$inc": bson.M{"token_version": 1}})
+	if err != nil {
+		return err
+	}
+	return RevokeOtherSessions(db, userID, primitive.NilObjectID)
+}
@@ -0,0 +1,141 @@
+package helpers
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestRotateSession(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	sessionID := primitive.NewObjectID()
+	userID := primitive.NewObjectID()
+	refreshToken := sessionID.Hex() + ".oldsecret"
+	oldHash := hashRefreshSecret("oldsecret")
+
+	found := func(tokenVersion int) bson.D {
+		return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+			{Key: "_id", Value: sessionID},
+			{Key: "user_id", Value: userID},
+			{Key: "token_version", Value: tokenVersion},
+			{Key: "expires_at", Value: time.Now().Add(time.Hour)},
+		}})
+	}
+	notFound := mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil})
+	user := mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: userID}, {Key: "token_version", Value: 0},
+	})
+	revoked := mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1})
+
+	tests := []struct {
+		name      string
+		token     string
+		responses []bson.D
+		wantErr   bool
+		wantCmds  []string
+	}{
+		{
+			name:      "current token is rotated",
+			token:     refreshToken,
+			responses: []bson.D{found(0), user},
+			wantCmds:  []string{"findAndModify", "find"},
+		},
+		{
+			name:      "replaced token inside the grace window",
+			token:     refreshToken,
+			responses: []bson.D{notFound, found(0), user},
+			wantCmds:  []string{"findAndModify", "findAndModify", "find"},
+		},
+		{
+			name:      "replaced token after the grace window revokes the session",
+			token:     refreshToken,
+			responses: []bson.D{notFound, notFound, revoked},
+			wantErr:   true,
+			wantCmds:  []string{"findAndModify", "findAndModify", "update"},
+		},
+		{
+			name:      "session from before a password change",
+			token:     refreshToken,
+			responses: []bson.D{found(1), user, revoked},
+			wantErr:   true,
+			wantCmds:  []string{"findAndModify", "find", "update"},
+		},
+		{
+			name:    "malformed token",
+			token:   "not-a-token",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(tt.responses...)
+
+			session, _, newToken, err := RotateSession(mt.DB, tt.token, "127.0.0.1")
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRefreshToken) {
+					mt.Fatalf("RotateSession() error = %v, want ErrInvalidRefreshToken", err)
+				}
+			} else {
+				if err != nil {
+					mt.Fatalf("RotateSession() error = %v", err)
+				}
+				if session.ID != sessionID || newToken == tt.token {
+					mt.Errorf("RotateSession() = %s, %q, want session %s with a new token", session.ID.Hex(), newToken, sessionID.Hex())
+				}
+			}
+			if got := commandNames(mt); !slices.Equal(got, tt.wantCmds) {
+				mt.Errorf("commands = %v, want %v", got, tt.wantCmds)
+			}
+		})
+	}
+
+	mt.Run("rotation keeps the replaced hash for reuse detection", func(mt *mtest.T) {
+		mt.AddMockResponses(found(0), user)
+		if _, _, _, err := RotateSession(mt.DB, refreshToken, "127.0.0.1"); err != nil {
+			mt.Fatal(err)
+		}
+		cmd := mt.GetAllStartedEvents()[0].Command
+		if got := cmd.Lookup("query", "refresh_hash").StringValue(); got != oldHash {
+			mt.Errorf("matched refresh_hash = %s, want %s", got, oldHash)
+		}
+		if got := cmd.Lookup("update", "$set", "previous_hash").StringValue(); got != oldHash {
+			mt.Errorf("previous_hash = %s, want %s", got, oldHash)
+		}
+	})
+
+	mt.Run("the grace window can't be stretched", func(mt *mtest.T) {
+		mt.AddMockResponses(notFound, found(0), user)
+		if _, _, _, err := RotateSession(mt.DB, refreshToken, "127.0.0.1"); err != nil {
+			mt.Fatal(err)
+		}
+		cmd := mt.GetAllStartedEvents()[1].Command
+		if got := cmd.Lookup("query", "previous_hash").StringValue(); got != oldHash {
+			mt.Errorf("matched previous_hash = %s, want %s", got, oldHash)
+		}
+		since := cmd.Lookup("query", "last_used_at", "$gte").Time()
+		if d := time.Since(since); d < refreshReuseGrace-time.Second || d > refreshReuseGrace+time.Second {
+			mt.Errorf("grace window = %v, want about %v", d, refreshReuseGrace)
+		}
+		if _, err := cmd.LookupErr("update", "$set", "last_used_at"); err == nil {
+			mt.Error("a grace rotation must not move last_used_at")
+		}
+	})
+
+	mt.Run("reuse revokes only the session holding that hash", func(mt *mtest.T) {
+		mt.AddMockResponses(notFound, notFound, revoked)
+		_, _, _, _ = RotateSession(mt.DB, refreshToken, "127.0.0.1")
+		update := mt.GetAllStartedEvents()[2].Command.Lookup("updates", "0").Document()
+		if got := update.Lookup("q", "previous_hash").StringValue(); got != oldHash {
+			mt.Errorf("revoked previous_hash = %s, want %s", got, oldHash)
+		}
+		if _, err := update.LookupErr("u", "$set", "revoked_at"); err != nil {
+			mt.Errorf("revoked_at not set: %v", err)
+		}
+	})
+}
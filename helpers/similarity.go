@@ -0,0 +1,259 @@
+package helpers
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+// Reviews are compared with MinHash signatures built from word shingles.
+// Two signatures agree on roughly the same fraction of slots as the Jaccard
+// similarity of the underlying shingle sets.
+const (
+	shingleSize   = 3
+	signatureSize = 64
+	bandRows      = 2 // slots per LSH band; reviews sharing any band are compared
+
+	SimilarityMatchThreshold = 0.5  // matches at or above this are shown to the moderator
+	PlagiarismThreshold      = 0.85 // matches at or above this flag the review automatically
+	maxSimilarMatches        = 5
+	maxSimilarityCandidates  = 500
+)
+
+// SimilarityResult is what a new review gets stamped with before it is stored
+type SimilarityResult struct {
+	Signature  []uint32
+	Bands      []int64
+	Matches    []models.SimilarityMatch
+	Flagged    bool
+	FlagReason string
+}
+
+// normalizeWords lowercases the text and drops punctuation so that small
+// formatting changes don't hide a copy
+func normalizeWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func shingles(text string) []uint64 {
+	words := normalizeWords(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	size := shingleSize
+	if len(words) < size {
+		size = len(words)
+	}
+
+	seen := make(map[uint64]bool)
+	var out []uint64
+	for i := 0; i+size <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+size], " ")))
+		sum := h.Sum64()
+		if !seen[sum] {
+			seen[sum] = true
+			out = append(out, sum)
+		}
+	}
+	return out
+}
+
+// mix is splitmix64, used to derive the independent hash functions
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// ReviewSignature builds the MinHash signature of a review text
+func ReviewSignature(text string) []uint32 {
+	sh := shingles(text)
+	if len(sh) == 0 {
+		return nil
+	}
+
+	sig := make([]uint32, signatureSize)
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+	for _, s := range sh {
+		for i := 0; i < signatureSize; i++ {
+			v := uint32(mix(s ^ mix(uint64(i))))
+			if v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// EstimateSimilarity returns the estimated Jaccard similarity of two signatures
+func EstimateSimilarity(a, b []uint32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(a))
+}
+
+// SignatureBands splits a signature into LSH band keys. Reviews with an estimated
+// similarity of 0.5 share at least one band with near certainty, so an index on the
+// keys finds every likely match without reading all reviews
+func SignatureBands(sig []uint32) []int64 {
+	if len(sig) == 0 {
+		return nil
+	}
+	bands := make([]int64, 0, len(sig)/bandRows)
+	for b := 0; b+bandRows <= len(sig); b += bandRows {
+		key := mix(uint64(b))
+		for _, v := range sig[b : b+bandRows] {
+			key = mix(key ^ uint64(v))
+		}
+		bands = append(bands, int64(key))
+	}
+	return bands
+}
+
+// MigrateReviewSignatures indexes the band keys and stores the signature of every
+// review saved before signatures or bands existed, returning how many were updated
+func MigrateReviewSignatures(db *mongo.Database) (int, error) {
+	reviewsCol := db.Collection("Reviews")
+	if _, err := reviewsCol.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "minhash_bands", Value: 1}},
+	}); err != nil {
+		return 0, err
+	}
+
+	cursor, err := reviewsCol.Find(context.Background(), bson.M{"minhash_bands": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"review_text": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	updated := 0
+	for cursor.Next(context.Background()) {
+		var review models.Review
+		if err := cursor.Decode(&review); err != nil {
+			return updated, err
+		}
+		sig := ReviewSignature(review.ReviewText)
+		bands := SignatureBands(sig)
+		if bands == nil {
+			bands = []int64{} // nothing to compare, but marks the review as done
+		}
+		if _, err := reviewsCol.UpdateOne(context.Background(), bson.M{"_id": review.ID}, bson.M{
+			"$set": bson.M{"minhash": sig, "minhash_bands": bands},
+		}); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, cursor.Err()
+}
+
+// CheckReviewSimilarity compares a new review against the stored reviews that share
+// a band with it and returns the closest matches
+func CheckReviewSimilarity(db *mongo.Database, userID, bookID primitive.ObjectID, text string) (SimilarityResult, error) {
+	result := SimilarityResult{Signature: ReviewSignature(text)}
+	if result.Signature == nil {
+		return result, nil
+	}
+	result.Bands = SignatureBands(result.Signature)
+	normalized := strings.Join(normalizeWords(text), " ")
+
+	reviewsCol := db.Collection("Reviews")
+	// the reviews sharing the most bands are the likeliest copies, so those are the
+	// ones kept when a common text matches more than maxSimilarityCandidates
+	cursor, err := reviewsCol.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"minhash_bands": bson.M{"$in": result.Bands},
+			// a student's own review of the same book is not a copy
+			"$nor": bson.A{bson.M{"user_id": userID, "book_id": bookID}},
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"shared_bands": bson.M{"$size": bson.M{"$setIntersection": bson.A{"$minhash_bands", result.Bands}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "shared_bands", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$limit", Value: maxSimilarityCandidates}},
+		{{Key: "$project", Value: bson.M{
+			"user_id":     1,
+			"reader_id":   1,
+			"book_id":     1,
+			"review_text": 1,
+			"minhash":     1,
+		}}},
+	})
+	if err != nil {
+		return result, err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var other models.Review
+		if err := cursor.Decode(&other); err != nil {
+			continue
+		}
+
+		score := EstimateSimilarity(result.Signature, other.MinHash)
+		if strings.Join(normalizeWords(other.ReviewText), " ") == normalized {
+			score = 1
+		}
+		if score < SimilarityMatchThreshold {
+			continue
+		}
+
+		result.Matches = append(result.Matches, models.SimilarityMatch{
+			ReviewID:   other.ID,
+			UserID:     other.UserID,
+			ReaderID:   other.ReaderID,
+			BookID:     other.BookID,
+			Similarity: score,
+			SameAuthor: other.UserID == userID,
+		})
+	}
+	if err := cursor.Err(); err != nil {
+		return result, err
+	}
+
+	sort.Slice(result.Matches, func(i, j int) bool {
+		return result.Matches[i].Similarity > result.Matches[j].Similarity
+	})
+	if len(result.Matches) > maxSimilarMatches {
+		result.Matches = result.Matches[:maxSimilarMatches]
+	}
+
+	for _, m := range result.Matches {
+		if m.Similarity < PlagiarismThreshold {
+			break
+		}
+		result.Flagged = true
+		if !m.SameAuthor {
+			// copying someone else outranks reusing your own text
+			result.FlagReason = "copied_from_other_student"
+			break
+		}
+		result.FlagReason = "duplicate_of_own_review"
+	}
+
+	return result, nil
+}
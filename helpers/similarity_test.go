@@ -0,0 +1,134 @@
+package helpers
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+const sampleReview = "The author follows a young engineer who returns to her village and slowly " +
+	"rebuilds the water system her grandfather designed, and every chapter adds another " +
+	"neighbour who doubts her until the final flood proves the old plans right. The pacing " +
+	"is slow in the middle but the ending made the whole book worth reading for me."
+
+func TestEstimateSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		min, max float64
+	}{
+		{"identical", sampleReview, sampleReview, 1, 1},
+		{"case and punctuation ignored",
+			sampleReview,
+			"the author follows a young engineer, who returns to her village -- and slowly " +
+				"rebuilds the water system her grandfather designed and every chapter adds another " +
+				"neighbour who doubts her until the final flood proves the old plans right the pacing " +
+				"is slow in the middle but the ending made the whole book worth reading for me!",
+			1, 1},
+		{"one word changed",
+			sampleReview,
+			"The author follows a young engineer who returns to her town and slowly " +
+				"rebuilds the water system her grandfather designed, and every chapter adds another " +
+				"neighbour who doubts her until the final flood proves the old plans right. The pacing " +
+				"is slow in the middle but the ending made the whole book worth reading for me.",
+			SimilarityMatchThreshold, 1},
+		{"unrelated",
+			sampleReview,
+			"A collection of short poems about city life at night, mostly about trains, " +
+				"rain on windows and the people who work while everyone else is asleep.",
+			0, SimilarityMatchThreshold},
+		{"empty", "", sampleReview, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EstimateSimilarity(ReviewSignature(tt.a), ReviewSignature(tt.b))
+			if got < tt.min || got > tt.max {
+				t.Errorf("EstimateSimilarity() = %.2f, want between %.2f and %.2f", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestSignatureBands(t *testing.T) {
+	sig := ReviewSignature(sampleReview)
+	if got := len(SignatureBands(sig)); got != signatureSize/bandRows {
+		t.Fatalf("len(SignatureBands()) = %d, want %d", got, signatureSize/bandRows)
+	}
+	if SignatureBands(nil) != nil {
+		t.Fatal("SignatureBands(nil) should be nil")
+	}
+
+	shared := func(a, b []int64) bool {
+		seen := make(map[int64]bool)
+		for _, k := range a {
+			seen[k] = true
+		}
+		for _, k := range b {
+			if seen[k] {
+				return true
+			}
+		}
+		return false
+	}
+
+	tests := []struct {
+		name  string
+		other string
+		want  bool
+	}{
+		{"copy shares a band", sampleReview + " Highly recommended.", true},
+		{"unrelated shares none", "A collection of short poems about city life at night, mostly about trains, " +
+			"rain on windows and the people who work while everyone else is asleep.", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shared(SignatureBands(sig), SignatureBands(ReviewSignature(tt.other))); got != tt.want {
+				t.Errorf("share a band = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReviewSimilarityRanksCandidates(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("candidates sharing the most bands are compared first", func(mt *mtest.T) {
+		copied := bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "user_id", Value: primitive.NewObjectID()},
+			{Key: "review_text", Value: sampleReview},
+			{Key: "minhash", Value: ReviewSignature(sampleReview)},
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.Reviews", mtest.FirstBatch, copied))
+
+		result, err := CheckReviewSimilarity(mt.DB, primitive.NewObjectID(), primitive.NewObjectID(), sampleReview)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if len(result.Matches) != 1 || !result.Flagged {
+			mt.Errorf("matches = %d, flagged = %v, want 1 flagged match", len(result.Matches), result.Flagged)
+		}
+
+		stages, _ := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Values()
+		var sortKey string
+		var limit int32
+		for _, v := range stages {
+			stage := v.Document()
+			if s, err := stage.LookupErr("$sort"); err == nil {
+				elems, _ := s.Document().Elements()
+				sortKey = elems[0].Key()
+			}
+			if l, err := stage.LookupErr("$limit"); err == nil {
+				limit = l.Int32()
+			}
+		}
+		if sortKey != "shared_bands" {
+			mt.Errorf("candidates sorted by %q, want shared_bands", sortKey)
+		}
+		if limit != maxSimilarityCandidates {
+			mt.Errorf("limit = %d, want %d", limit, maxSimilarityCandidates)
+		}
+	})
+}
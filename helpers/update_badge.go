@@ -11,66 +11,6 @@ import (
 	"reading-tracker/backend/models" // replace with your actual import path
 )
 
-// Badge definitions
-var achievementBadges = []struct {
-	Name       string
-	Score      int
-	CriteriaFn func(user models.User, db *mongo.Database) bool
-}{
-	{"Book Worm", 3, func(u models.User, db *mongo.Database) bool { return u.BooksRead >= 4 }},
-	{"Marathon Reader", 5, func(u models.User, db *mongo.Database) bool { return u.BooksRead >= 8 }},
-	{"Page Turner", 2, func(u models.User, db *mongo.Database) bool { return u.BooksRead >= 5 }},
-	{"Streak Keeper", 4, func(u models.User, db *mongo.Database) bool {
-		progress := db.Collection("ReadingProgress")
-		count, _ := progress.CountDocuments(context.Background(), bson.M{"user_id": u.ID, "streak_days": bson.M{"$gte": 7}})
-		return count > 0
-	}},
-	{"Upvoted Author", 3, func(u models.User, db *mongo.Database) bool {
-		reviews := db.Collection("Reviews")
-		count, _ := reviews.CountDocuments(context.Background(), bson.M{"user_id": u.ID, "upvotes": bson.M{"$gte": 5}})
-		return count > 0
-	}},
-	{"Community Helper", 3, func(u models.User, db *mongo.Database) bool {
-		reviews := db.Collection("Reviews")
-		count, _ := reviews.CountDocuments(context.Background(), bson.M{"user_id": u.ID, "upvotes": bson.M{"$gte": 1}})
-		return count >= 3
-	}},
-	{"Daily Reader", 2, func(u models.User, db *mongo.Database) bool {
-		progress := db.Collection("ReadingProgress")
-		count, _ := progress.CountDocuments(context.Background(), bson.M{"user_id": u.ID, "last_updated": bson.M{"$gte": time.Now().Add(-24 * time.Hour)}})
-		return count > 0
-	}},
-	{"Quote Contributor", 3, func(u models.User, db *mongo.Database) bool {
-		quotes := db.Collection("Quotes")
-		count, _ := quotes.CountDocuments(context.Background(), bson.M{"user_id": u.ID})
-		return count > 0
-	}},
-	{"Popular Quote", 5, func(u models.User, db *mongo.Database) bool {
-		quotes := db.Collection("Quotes")
-		count, _ := quotes.CountDocuments(context.Background(), bson.M{"user_id": u.ID, "upvotes": bson.M{"$gte": 10}})
-		return count > 0
-	}},
-}
-
-// ClassTag thresholds based on time spent on platform
-func determineClassTag(user models.User) string {
-	now := time.Now()
-	duration := now.Sub(user.CreatedAt)
-
-	switch {
-	case duration < 30*24*time.Hour: // less than 1 month
-		return "Beginner"
-	case duration < 3*30*24*time.Hour: // 1-3 months
-		return "Casual"
-	case duration < 10*30*24*time.Hour: // 3-10 months
-		return "Regular"
-	case duration < 12*30*24*time.Hour: // 10-12 months
-		return "Dedicated"
-	default: // >= 1 year
-		return "Family"
-	}
-}
-
 // UpdateUserBadgesAndClassTag updates badges, class-tag, and rank score automatically
 func UpdateUserBadgesAndClassTag(userID primitive.ObjectID, db *mongo.Database) error {
 	usersCol := db.Collection("users")
@@ -82,52 +22,61 @@ func UpdateUserBadgesAndClassTag(userID primitive.ObjectID, db *mongo.Database)
 		return err
 	}
 
-	totalScore := 0
+	catalog, err := BadgeCatalog(db, true)
+	if err != nil {
+		return err
+	}
+	counters, err := UserBadgeCounters(db, user)
+	if err != nil {
+		return err
+	}
+
+	// badges an admin revoked are not given back automatically
+	revoked := make(map[string]bool)
+	names, _ := db.Collection("BadgeAudits").Distinct(context.Background(), "badge_name", bson.M{"user_id": user.ID, "action": "revoke"})
+	for _, name := range names {
+		if n, ok := name.(string); ok {
+			revoked[n] = true
+		}
+	}
 
-	// Process each badge
-	for _, badgeDef := range achievementBadges {
+	// Process each badge; only a newly earned badge adds to the score
+	for _, badgeDef := range catalog {
 		// Check if user already has badge
 		count, _ := badgesCol.CountDocuments(context.Background(), bson.M{"user_id": user.ID, "name": badgeDef.Name})
-		if count > 0 {
-			totalScore += badgeDef.Score
+		if count > 0 || revoked[badgeDef.Name] {
 			continue
 		}
 
-		// Evaluate criteria
-		if badgeDef.CriteriaFn(user, db) {
-			_, _ = badgesCol.InsertOne(context.Background(), bson.M{
-				"user_id":     user.ID,
-				"name":        badgeDef.Name,
-				"type":        "achievement",
-				"description": "Earned the " + badgeDef.Name + " badge!",
-				"created_at":  time.Now(),
+		// Evaluate the rule; the unique index on user_id and name turns a badge another
+		// worker awarded in the meantime into a duplicate key error, which is skipped
+		if BadgeEarned(badgeDef, counters) {
+			res, err := badgesCol.InsertOne(context.Background(), models.Badge{
+				UserID:      user.ID,
+				Name:        badgeDef.Name,
+				Type:        "achievement",
+				Description: badgeDef.Description,
+				Icon:        badgeDef.Icon,
+				CreatedAt:   time.Now(),
 			})
-			totalScore += badgeDef.Score
+			if err == nil {
+				badgeID := res.InsertedID.(primitive.ObjectID)
+				_ = RecordActivity(db, user.ID, "badge_earned", primitive.NilObjectID, badgeID, badgeDef.Name)
+				_ = UpdateRankScore(db, user.ID, badgeDef.Score, ScoreReasonBadgeEarned, "badge", badgeID)
+				// this already runs on the event bus, so the webhooks are queued directly
+				_ = EnqueueWebhooks(db, DomainEvent{
+					ID:       primitive.NewObjectID(),
+					Type:     EventBadgeEarned,
+					UserID:   user.ID,
+					ActorID:  user.ID,
+					TargetID: badgeID,
+					At:       time.Now(),
+				})
+			}
 		}
 	}
 
-	classTag := determineClassTag(user)
-	// update badge for class-tag type
-	count, _ := badgesCol.CountDocuments(context.Background(), bson.M{"user_id": user.ID, "name": classTag})
-	if count > 0 {
-		_, _ = badgesCol.InsertOne(context.Background(), bson.M{
-			"user_id":     user.ID,
-			"name":        classTag,
-			"type":        "class-Tag",
-			"description": "Earned the " + classTag + " badge!",
-			"created_at":  time.Now(),
-		})
-	}
-	
-	// Update rank score
-	UpdateRankScore(db, user.ID, totalScore) // Adjust rank score
 	// Update ClassTag
-
-	// Update user document
-	_, err = usersCol.UpdateOne(context.Background(), bson.M{"_id": user.ID}, bson.M{
-		"$set": bson.M{
-			"class_tag": classTag,
-		},
-	})
+	_, err = ApplyClassTag(db, user)
 	return err
 }
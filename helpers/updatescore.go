@@ -1,21 +1,314 @@
-
 package helpers
 
-
 import (
-    "context"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/bson/primitive"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
 )
 
-func UpdateRankScore(db *mongo.Database, userID primitive.ObjectID, delta int) error {
-	usersCol := db.Collection("users")
+// Reason codes written on every score event
+const (
+	ScoreReasonReadingStarted = "reading_started" // first progress entry on a book
+	ScoreReasonReadingStreak  = "reading_streak"  // reading streak went up
+	ScoreReasonReviewApproved = "review_approved" // a review was approved the first time
+	ScoreReasonBookCompleted  = "book_completed"  // finishing a book (on review approval)
+	ScoreReasonReviewUpvote   = "review_upvote"   // upvote on your review, negative when removed
+	ScoreReasonReviewComment  = "review_comment"  // someone commented on your review
+	ScoreReasonQuoteUpvote    = "quote_upvote"    // upvote on your quote
+	ScoreReasonCommentUpvote  = "comment_upvote"  // like or upvote on your comment
+	ScoreReasonQuoteComment   = "quote_comment"   // you commented on a quote
+	ScoreReasonBadgeEarned    = "badge_earned"    // the score of a badge
+	ScoreReasonBadgeAwarded   = "badge_awarded"   // an admin gave a custom badge
+	ScoreReasonBadgeRevoked   = "badge_revoked"   // an admin took a badge away
+	ScoreReasonContentDeleted = "content_deleted" // points taken back when content is deleted
+	ScoreReasonOpeningBalance = "opening_balance" // score that existed before the ledger
+)
+
+// UpdateRankScore writes a score event and adds it to the user's rank_score,
+// which is always the sum of their events
+func UpdateRankScore(db *mongo.Database, userID primitive.ObjectID, delta int, reason, sourceType string, sourceID primitive.ObjectID) error {
+	return RecordScoreEvent(db, primitive.NilObjectID, userID, delta, reason, sourceType, sourceID)
+}
 
-	_, err := usersCol.UpdateOne(
-		context.Background(),
-		bson.M{"_id": userID},
-		bson.M{"$inc": bson.M{"rank_score": delta}},
+// RecordScoreEvent is UpdateRankScore for event workers: a change with an eventID is
+// written only once, however often its delivery is retried. The row is written as
+// pending and only the caller that clears the flag adds it to rank_score, so a retry
+// after a failed $inc applies it and a second worker on the same event doesn't
+func RecordScoreEvent(db *mongo.Database, eventID, userID primitive.ObjectID, delta int, reason, sourceType string, sourceID primitive.ObjectID) error {
+	if delta == 0 {
+		return nil
+	}
+	eventsCol := db.Collection("ScoreEvents")
+	res, err := eventsCol.InsertOne(context.Background(), models.ScoreEvent{
+		EventID:    eventID,
+		UserID:     userID,
+		Delta:      delta,
+		Reason:     reason,
+		SourceType: sourceType,
+		SourceID:   sourceID,
+		CreatedAt:  time.Now(),
+		Pending:    true,
+	})
+
+	filter := bson.M{"event_id": eventID}
+	switch {
+	case err == nil:
+		filter = bson.M{"_id": res.InsertedID}
+	case eventID.IsZero() || !mongo.IsDuplicateKeyError(err):
+		return err
+	}
+	// a duplicate event_id means an earlier attempt wrote the row; it may not have been applied
+	return applyScoreEvent(db, filter)
+}
+
+// applyScoreEvent adds a pending score event to its user's rank_score
+func applyScoreEvent(db *mongo.Database, filter bson.M) error {
+	eventsCol := db.Collection("ScoreEvents")
+	filter["pending"] = true
+
+	var event models.ScoreEvent
+	err := eventsCol.FindOneAndUpdate(context.Background(), filter, bson.M{"$unset": bson.M{"pending": ""}}).Decode(&event)
+	if err == mongo.ErrNoDocuments {
+		return nil // already applied
+	}
+	if err != nil {
+		return err
+	}
+
+	// $inc so concurrent events for the same user can't overwrite each other
+	_, err = db.Collection("users").UpdateOne(context.Background(),
+		bson.M{"_id": event.UserID},
+		bson.M{"$inc": bson.M{"rank_score": event.Delta}},
 	)
+	if err != nil {
+		// leave it for the retry
+		_, _ = eventsCol.UpdateOne(context.Background(), bson.M{"_id": event.ID}, bson.M{"$set": bson.M{"pending": true}})
+	}
 	return err
 }
+
+// ensureScoreEventIndexes indexes the ledger and makes event_id unique among the events
+// that have one. Copies written before that index existed were each added to
+// rank_score, so they are taken back out before it is created
+func ensureScoreEventIndexes(db *mongo.Database) error {
+	eventsCol := db.Collection("ScoreEvents")
+	cursor, err := eventsCol.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"event_id": bson.M{"$exists": true}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$event_id",
+			"rows":  bson.M{"$push": bson.M{"_id": "$_id", "user_id": "$user_id", "delta": "$delta"}},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	var groups []struct {
+		Rows []models.ScoreEvent `bson:"rows"`
+	}
+	if err := cursor.All(context.Background(), &groups); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		for _, extra := range g.Rows[1:] {
+			if _, err := eventsCol.DeleteOne(context.Background(), bson.M{"_id": extra.ID}); err != nil {
+				return err
+			}
+			if _, err := db.Collection("users").UpdateOne(context.Background(),
+				bson.M{"_id": extra.UserID},
+				bson.M{"$inc": bson.M{"rank_score": -extra.Delta}},
+			); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = eventsCol.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "event_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"event_id": bson.M{"$exists": true}}),
+		},
+		// a user's history, ledger total and active weeks
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		// what one badge or piece of content has scored
+		{Keys: bson.D{{Key: "source_type", Value: 1}, {Key: "source_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "pending", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"pending": true}),
+		},
+	})
+	return err
+}
+
+// ledgerTotals sums the score events per user, optionally for one user only
+func ledgerTotals(db *mongo.Database, match bson.M) (map[primitive.ObjectID]int, error) {
+	cursor, err := db.Collection("ScoreEvents").Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$user_id", "score": bson.M{"$sum": "$delta"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	totals := make(map[primitive.ObjectID]int)
+	for cursor.Next(context.Background()) {
+		var row struct {
+			UserID primitive.ObjectID `bson:"_id"`
+			Score  int                `bson:"score"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		totals[row.UserID] = row.Score
+	}
+	return totals, cursor.Err()
+}
+
+// MigrateScoreLedger gives every user whose score predates the ledger a one-time
+// opening balance event for the difference, so their history adds up to what they
+// had. It runs at startup before any event is written and marks users it has seen
+// with ledger_opened, so it is cheap to run again. It also indexes the ledger and
+// applies events left pending
+func MigrateScoreLedger(db *mongo.Database) (int, error) {
+	if err := ensureScoreEventIndexes(db); err != nil {
+		return 0, err
+	}
+	// events written but never added to rank_score, e.g. when a retry ran out
+	pending, err := db.Collection("ScoreEvents").Distinct(context.Background(), "_id", bson.M{"pending": true})
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range pending {
+		if err := applyScoreEvent(db, bson.M{"_id": id}); err != nil {
+			return 0, err
+		}
+	}
+
+	usersCol := db.Collection("users")
+	cursor, err := usersCol.Find(context.Background(), bson.M{"ledger_opened": bson.M{"$ne": true}},
+		options.Find().SetProjection(bson.M{"rank_score": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	opened := 0
+	for cursor.Next(context.Background()) {
+		var user struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			RankScore int                `bson:"rank_score"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return opened, err
+		}
+		totals, err := ledgerTotals(db, bson.M{"user_id": user.ID})
+		if err != nil {
+			return opened, err
+		}
+		if diff := user.RankScore - totals[user.ID]; diff != 0 {
+			_, err := db.Collection("ScoreEvents").InsertOne(context.Background(), models.ScoreEvent{
+				UserID:    user.ID,
+				Delta:     diff,
+				Reason:    ScoreReasonOpeningBalance,
+				CreatedAt: time.Now(),
+			})
+			if err != nil {
+				return opened, err
+			}
+			opened++
+		}
+		if _, err := usersCol.UpdateOne(context.Background(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"ledger_opened": true}}); err != nil {
+			return opened, err
+		}
+	}
+	return opened, cursor.Err()
+}
+
+// RecomputeResult is what an admin recompute changed
+type RecomputeResult struct {
+	UsersChecked    int `json:"users_checked"`
+	UsersChanged    int `json:"users_changed"`
+	OpeningBalances int `json:"opening_balances"`
+}
+
+// RecomputeAllScores opens the ledger of users that predate it and then sets every
+// rank_score back to the sum of the user's events, repairing any drift
+func RecomputeAllScores(db *mongo.Database) (RecomputeResult, error) {
+	var result RecomputeResult
+
+	opened, err := MigrateScoreLedger(db)
+	result.OpeningBalances = opened
+	if err != nil {
+		return result, err
+	}
+	totals, err := ledgerTotals(db, bson.M{})
+	if err != nil {
+		return result, err
+	}
+
+	usersCol := db.Collection("users")
+	cursor, err := usersCol.Find(context.Background(), bson.M{}, options.Find().SetProjection(bson.M{"rank_score": 1}))
+	if err != nil {
+		return result, err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var user struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			RankScore int                `bson:"rank_score"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			continue
+		}
+		result.UsersChecked++
+
+		if total := totals[user.ID]; user.RankScore != total {
+			if _, err := usersCol.UpdateOne(context.Background(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"rank_score": total}}); err != nil {
+				return result, err
+			}
+			result.UsersChanged++
+		}
+	}
+	return result, cursor.Err()
+}
+
+// BadgeScore is what a badge has added to its owner's rank score so far. A badge
+// from before the ledger has no events of its own (its points are in the opening
+// balance), so the catalog score for its name is used instead
+func BadgeScore(db *mongo.Database, badgeID primitive.ObjectID, badgeName string) (int, error) {
+	cursor, err := db.Collection("ScoreEvents").Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"source_type": "badge", "source_id": badgeID}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "score": bson.M{"$sum": "$delta"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+	var row struct {
+		Score int `bson:"score"`
+	}
+	if cursor.Next(context.Background()) {
+		err := cursor.Decode(&row)
+		return row.Score, err
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, err
+	}
+
+	var def models.BadgeDefinition
+	err = db.Collection("BadgeDefinitions").FindOne(context.Background(), bson.M{"name": badgeName}).Decode(&def)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return def.Score, err
+}
@@ -0,0 +1,219 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reading-tracker/backend/models"
+)
+
+// WebhookEventTypes are the events a webhook can subscribe to
+var WebhookEventTypes = []string{
+	EventBookBorrowed, EventBookReturned, EventReviewApproved, EventBadgeEarned,
+	EventReviewUpvoted, EventReviewCommented, EventQuoteAdded, EventProgressUpdated,
+}
+
+// how long a claimed delivery is left alone before it may be tried again
+const webhookLease = 2 * time.Minute
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookPayload is the JSON body subscribers receive
+func webhookPayload(db *mongo.Database, e DomainEvent) ([]byte, error) {
+	data := map[string]any{
+		"user_id":   e.UserID,
+		"actor_id":  e.ActorID,
+		"target_id": e.TargetID,
+	}
+	var user models.User
+	if err := db.Collection("users").FindOne(context.Background(), bson.M{"_id": e.UserID}).Decode(&user); err == nil {
+		data["reader_id"] = user.ReaderID
+		data["name"] = user.Name
+	}
+
+	switch e.Type {
+	case EventBookBorrowed, EventBookReturned, EventProgressUpdated:
+		var book models.Book
+		if err := db.Collection("books").FindOne(context.Background(), bson.M{"_id": e.TargetID}).Decode(&book); err == nil {
+			data["book_title"] = book.Title
+			data["isbn"] = book.ISBN
+		}
+	case EventBadgeEarned:
+		var badge models.Badge
+		if err := db.Collection("Badges").FindOne(context.Background(), bson.M{"_id": e.TargetID}).Decode(&badge); err == nil {
+			data["badge_name"] = badge.Name
+		}
+	}
+
+	return json.Marshal(map[string]any{
+		"id":          e.ID,
+		"type":        e.Type,
+		"occurred_at": e.At,
+		"data":        data,
+	})
+}
+
+// EnqueueWebhooks stores a delivery of the event for every active webhook subscribed
+// to its type. Doing it twice for the same event doesn't deliver twice
+func EnqueueWebhooks(db *mongo.Database, e DomainEvent) error {
+	cursor, err := db.Collection("Webhooks").Find(context.Background(), bson.M{"active": true, "events": e.Type})
+	if err != nil {
+		return err
+	}
+	var hooks []models.Webhook
+	if err := cursor.All(context.Background(), &hooks); err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	payload, err := webhookPayload(db, e)
+	if err != nil {
+		return err
+	}
+	deliveriesCol := db.Collection("WebhookDeliveries")
+	now := time.Now()
+	for _, hook := range hooks {
+		_, err := deliveriesCol.UpdateOne(context.Background(),
+			bson.M{"webhook_id": hook.ID, "event_id": e.ID},
+			bson.M{"$setOnInsert": models.WebhookDelivery{
+				WebhookID:     hook.ID,
+				EventID:       e.ID,
+				EventType:     e.Type,
+				Payload:       string(payload),
+				Status:        "pending",
+				NextAttemptAt: now,
+				CreatedAt:     now,
+			}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookWorker turns bus events into webhook deliveries
+func webhookWorker(db *mongo.Database, e DomainEvent) error {
+	if e.Undo {
+		return nil
+	}
+	return EnqueueWebhooks(db, e)
+}
+
+// SignWebhook is the X-Webhook-Signature of a body: hex HMAC-SHA256 of "timestamp.body"
+// with the webhook's secret, so receivers can check the sender and reject replays
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// StartWebhookDeliveries sends due deliveries every few seconds. Failures are retried
+// with exponential backoff; after WEBHOOK_MAX_ATTEMPTS (default 8) the delivery is dead
+func StartWebhookDeliveries(db *mongo.Database) {
+	maxAttempts := 8
+	if n, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && n > 0 {
+		maxAttempts = n
+	}
+
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			for deliverNextWebhook(db, maxAttempts) {
+			}
+		}
+	}()
+}
+
+// deliverNextWebhook claims one due delivery and posts it; false when nothing is due
+func deliverNextWebhook(db *mongo.Database, maxAttempts int) bool {
+	deliveriesCol := db.Collection("WebhookDeliveries")
+	now := time.Now()
+
+	var d models.WebhookDelivery
+	err := deliveriesCol.FindOneAndUpdate(context.Background(),
+		bson.M{"status": "pending", "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(webhookLease)}, "$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetSort(bson.M{"next_attempt_at": 1}).SetReturnDocument(options.After),
+	).Decode(&d)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("webhooks: %v", err)
+		}
+		return false
+	}
+
+	var hook models.Webhook
+	if err := db.Collection("Webhooks").FindOne(context.Background(), bson.M{"_id": d.WebhookID}).Decode(&hook); err != nil || !hook.Active {
+		_, _ = deliveriesCol.UpdateByID(context.Background(), d.ID, bson.M{"$set": bson.M{"status": "dead", "last_error": "webhook removed or disabled"}})
+		return true
+	}
+
+	statusCode, err := postWebhook(hook, d)
+	if err == nil {
+		_, _ = deliveriesCol.UpdateByID(context.Background(), d.ID, bson.M{
+			"$set":   bson.M{"status": "delivered", "delivered_at": time.Now(), "last_status_code": statusCode},
+			"$unset": bson.M{"last_error": ""},
+		})
+		return true
+	}
+
+	update := bson.M{"last_error": err.Error(), "last_status_code": statusCode}
+	if d.Attempts >= maxAttempts {
+		update["status"] = "dead"
+		log.Printf("webhooks: delivery %s to %s is dead after %d attempts: %v", d.ID.Hex(), hook.URL, d.Attempts, err)
+	} else {
+		// 30s, 1m, 2m, 4m... up to a day
+		update["next_attempt_at"] = time.Now().Add(retryDelay(30*time.Second, d.Attempts))
+	}
+	_, _ = deliveriesCol.UpdateByID(context.Background(), d.ID, bson.M{"$set": update})
+	return true
+}
+
+func postWebhook(hook models.Webhook, d models.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "reading-tracker-webhooks")
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Delivery", d.ID.Hex())
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", SignWebhook(hook.Secret, timestamp, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,26 @@
+package helpers
+
+import "testing"
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"review_approved"}`)
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		want      string
+	}{
+		{"known signature", "s3cret", "1700000000", body, "sha256=e50ff64e6741e450b18b9bcb7489732c858682b3f9cdfce34c3031f596c7df1e"},
+		{"timestamp is signed", "s3cret", "1700000001", body, "sha256=486f0a1ae6f912ada64cf0eb35f0fba5b62047cecf7f36ed0d601bd0170763ba"},
+		{"secret is used", "other", "1700000000", body, "sha256=4b8c4877fd16941265744985ed2e9ee7c8e15db763df0e2b3bd390b94ffc7e98"},
+		{"empty body", "s3cret", "1700000000", nil, "sha256=21948100f1d7a89f3338f6b1106fc4f7a702fbe1493b833a3382f80193bde3fe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignWebhook(tt.secret, tt.timestamp, tt.body); got != tt.want {
+				t.Errorf("SignWebhook() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 
 	"reading-tracker/backend/handlers"
+	"reading-tracker/backend/helpers"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
@@ -41,9 +42,67 @@ func main() {
 
 	// check this part works and also check how method instances work in python work before moving to this! maybe that is useful
 	authHandler := &handlers.AuthHandler{DB: db}
-	bookHandler := &handlers.BookHandler{DB: db}
-	socialHandler := &handlers.SocialHandler{DB: db}
+	// reviews stored before similarity bands existed get them now, so lookups can use the index
+	if updated, err := helpers.MigrateReviewSignatures(db); err != nil {
+		log.Fatal(err)
+	} else if updated > 0 {
+		log.Printf("stored similarity signatures of %d reviews", updated)
+	}
+	// reviews approved before approved_before existed must not be credited again
+	if updated, err := helpers.MigrateApprovedReviews(db); err != nil {
+		log.Fatal(err)
+	} else if updated > 0 {
+		log.Printf("marked %d reviews as approved before", updated)
+	}
+	// badges, scores and upvote notifications are worked out in the background from domain events
+	// scores from before the ledger become opening balances before any event is written
+	if opened, err := helpers.MigrateScoreLedger(db); err != nil {
+		log.Fatal(err)
+	} else if opened > 0 {
+		log.Printf("opened the score ledger of %d users", opened)
+	}
+	// starter badges were once written to "badges" under the pending registration's id
+	if moved, err := helpers.MigrateStarterBadges(db); err != nil {
+		log.Fatal(err)
+	} else if moved > 0 {
+		log.Printf("moved %d starter badges into Badges", moved)
+	}
+	// a badge can be held once; duplicates from concurrent awards are removed first
+	if removed, err := helpers.EnsureBadgeIndexes(db); err != nil {
+		log.Fatal(err)
+	} else if removed > 0 {
+		log.Printf("removed %d duplicate badges", removed)
+	}
+	if err := helpers.EnsureEventIndexes(db); err != nil {
+		log.Fatal(err)
+	}
+	events := helpers.NewEventBus(db)
+	helpers.RegisterEventWorkers(events)
+	events.Start(4)
+
+	// notifications written by any instance reach the streams open on this one
+	helpers.WatchNotifications(db, helpers.Notifications)
+
+	// recurring tasks (class tags, digests, clean up...) run on cron schedules, one instance at a time
+	jobs := helpers.NewScheduler(db)
+	if err := helpers.RegisterJobs(jobs); err != nil {
+		log.Fatal(err)
+	}
+	jobs.Start()
+
+	// emails are queued in the outbox and sent by MAIL_DRIVER (smtp or the development log)
+	helpers.StartOutbox(db, helpers.NewMailerFromEnv())
+
+	// signed webhook deliveries, retried until they are dead
+	helpers.StartWebhookDeliveries(db)
+
+	bookHandler := &handlers.BookHandler{DB: db, Events: events}
+	socialHandler := &handlers.SocialHandler{DB: db, Events: events, Jobs: jobs}
+	clubHandler := &handlers.ClubHandler{DB: db}
 	router := mux.NewRouter()
+	// tokens of revoked sessions are turned away, and tokens of users who still have to
+	// change their password only work for /change-password
+	router.Use(handlers.TokenGuard(db))
 
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Reading Tracker Backend is running!"))
@@ -53,6 +112,15 @@ func main() {
 	router.HandleFunc("/register", authHandler.Register).Methods("POST")        // working
 	router.HandleFunc("/login", authHandler.Login).Methods("POST")              //  working
 	router.HandleFunc("/approve-user", authHandler.ApproveUser).Methods("POST") // working
+	router.HandleFunc("/reject-user", authHandler.RejectUser).Methods("POST")   // this will reject a pending registration and email the student (body email, reason)
+	router.HandleFunc("/password/forgot", authHandler.ForgotPassword).Methods("POST")           // this will email a one-time reset link (same answer for unknown emails)
+	router.HandleFunc("/password/reset", authHandler.ResetPassword).Methods("POST")             // this will set a new password with the reset token (body token, new_password)
+	router.HandleFunc("/password/force-reset", authHandler.ForcePasswordReset).Methods("POST") // this will make a reader change their password at next login (admin only)
+	router.HandleFunc("/token/refresh", authHandler.RefreshToken).Methods("POST")              // this will swap a refresh token for a new access and refresh token
+	router.HandleFunc("/logout", authHandler.Logout).Methods("POST")                           // this will end the current session (body all: true ends every session)
+	router.HandleFunc("/me/sessions", authHandler.ListSessions).Methods("GET")                 // this will list the devices you are logged in on
+	router.HandleFunc("/me/sessions/{id}", authHandler.DeleteSession).Methods("DELETE")        // this will log you out on one device
+	router.HandleFunc("/revoke-sessions", authHandler.RevokeUserSessions).Methods("POST")      // this will end every session of a user (admin only, body user_id or reader_id)
 	router.HandleFunc("/bootstrap-admin", authHandler.BootstrapAdmin).Methods("POST")  // working
 	router.HandleFunc("/add-admin", authHandler.AddAdmin).Methods("POST")              //working 
 	router.HandleFunc("/change-password", authHandler.ChangePassword).Methods("POST")  // working
@@ -68,6 +136,7 @@ func main() {
 	router.HandleFunc("/reading-progress", bookHandler.UpdateReadingProgress).Methods("POST")   // working-- this will add a book into a reading progress
 	router.HandleFunc("/submit-review", bookHandler.SubmitReview).Methods("POST")               // working--this will enable the user to submit a review
 	router.HandleFunc("/approve-review", bookHandler.ApproveReview).Methods("POST")             // working--this approves the reading progress admin previalige
+	router.HandleFunc("/pending-reviews", bookHandler.ListPendingReviews).Methods("GET")        // this lists reviews waiting for approval with their closest matches for the admin
 	router.HandleFunc("/add-soft-to-reading", bookHandler.AddToReading).Methods("POST")         // working- this adds the softcopy book into reading list
 	router.HandleFunc("/user-reading-progress", bookHandler.ShowReadingProgress).Methods("GET") // working this shows the reading progress of the user
 	router.HandleFunc("/user-borrow-history", bookHandler.ShowBorrowHistory).Methods("GET")     // working this shows the borrow history of the user
@@ -78,7 +147,7 @@ func main() {
 	// Social features routes
 	router.HandleFunc("/public-reviews", socialHandler.PublicReviews).Methods("GET")             // working this will help any user to see the public reviews. (has query param isbn)
 	router.HandleFunc("/toggle-upvote", socialHandler.ToggleUpvote).Methods("POST")            			// working this will help any user to upvote or remove upvote from a review
-	router.HandleFunc("/leader-board", socialHandler.Leaderboard).Methods("GET")           			// working this will show the leader board of the users.(has query param limit)-accessable to all
+	router.HandleFunc("/leader-board", socialHandler.Leaderboard).Methods("GET")           			// working this will show the leader board of the users.(has query params limit, period, insa_batch, dorm_number, educational_status, group_by)-accessable to all
 	router.HandleFunc("/user-profile", socialHandler.UserProfile).Methods("GET")           				// working this will show the profile of a user-accessable to all        
 	router.HandleFunc("/recommendations", socialHandler.GetRecommendations).Methods("GET")    			// working this will give book recommendations based on the user's reading history
 	router.HandleFunc("/post-comment-review", socialHandler.PostCommentReview).Methods("POST")        // working this will help any user to comment on a review     
@@ -87,13 +156,78 @@ func main() {
 	router.HandleFunc("/toggle-quote-upvote", socialHandler.ToggleUpvoteQuote).Methods("POST")     // working this will help any user to upvote or remove upvote from a quote   
 	router.HandleFunc("/post-comment-quote", socialHandler.AddCommentQuote).Methods("POST")	   // working this will help any user to comment on a quote		  
 	router.HandleFunc("/toggle-comment-quote-upvote", socialHandler.ToggleCommentUpvoteQuote).Methods("POST")  // working this will help any user to upvote or remove upvote from a comment on a quote
-	router.HandleFunc("/list-notifications", socialHandler.ListNotifications).Methods("GET") // working this will help any user to see their notifications      
+	router.HandleFunc("/comments", socialHandler.CommentThread).Methods("GET")                  // this returns the threaded comments of a review or quote (has query params type and id)
+	router.HandleFunc("/list-notifications", socialHandler.ListNotifications).Methods("GET") // working this will help any user to see their notifications (has query params page, limit and group)
 	router.HandleFunc("/mark-notification-seen", socialHandler.MarkNotificationsSeen).Methods("POST") // working this will help any user to mark their notifications as seen
+	router.HandleFunc("/notifications/stream", socialHandler.NotificationStream).Methods("GET")    // this pushes new notifications as server-sent events (resumes with Last-Event-ID)
+	router.HandleFunc("/notification-preferences", socialHandler.GetNotificationPreferences).Methods("GET")   // this will show how each notification type is delivered (instant, digest or off)
+	router.HandleFunc("/notification-preferences", socialHandler.SaveNotificationPreferences).Methods("POST") // this will change how some notification types are delivered
 	router.HandleFunc("/search-books", bookHandler.SearchBooks).Methods("GET")  						// working this will help to search the book using different queries like genre title, author
 	router.HandleFunc("/search-reviews", socialHandler.SearchReviews).Methods("GET")           // working this will help to search reviews using keywords 
 	router.HandleFunc("/search-quotes", socialHandler.SearchQuotes).Methods("GET")             // working this will help to search quotes using keywords 
+	router.HandleFunc("/books/{isbn}/quotes", socialHandler.BookQuotes).Methods("GET")         // this lists the quotes taken from a book, most upvoted first
 	router.HandleFunc("/search-users", socialHandler.SearchUsers).Methods("GET")          // working this will help to search users using keywords like name reader id insa batch dorm number educational status
 	router.HandleFunc("/analytics", socialHandler.Analytics).Methods("GET")  							// working it will give total analysis of things for the admin ! 
+	// Editing and deleting your own content (admins can act on anyone's)
+	router.HandleFunc("/edit-review", socialHandler.EditReview).Methods("POST")                     // this will let the author fix a review, approved reviews go back to moderation
+	router.HandleFunc("/delete-review", socialHandler.DeleteReview).Methods("DELETE")               // this will delete a review with its comments and notifications
+	router.HandleFunc("/edit-review-comment", socialHandler.EditReviewComment).Methods("POST")      // this will let the author fix a comment on a review
+	router.HandleFunc("/delete-review-comment", socialHandler.DeleteReviewComment).Methods("DELETE") // this will delete a comment on a review
+	router.HandleFunc("/edit-quote", socialHandler.EditQuote).Methods("POST")                       // this will let the author fix a quote
+	router.HandleFunc("/delete-quote", socialHandler.DeleteQuote).Methods("DELETE")                 // this will delete a quote with its comments and notifications
+	router.HandleFunc("/edit-quote-comment", socialHandler.EditQuoteComment).Methods("POST")        // this will let the author fix a comment on a quote
+	router.HandleFunc("/delete-quote-comment", socialHandler.DeleteQuoteComment).Methods("DELETE")  // this will delete a comment on a quote
+	router.HandleFunc("/content-revisions", socialHandler.ListRevisions).Methods("GET")             // this shows the edit history of a review, quote or comment (has query params type and id)
+	// Following other readers
+	router.HandleFunc("/follow", socialHandler.Follow).Methods("POST")                              // this will let a user follow another reader (user_id or reader_id)
+	router.HandleFunc("/unfollow", socialHandler.Unfollow).Methods("POST")                          // this will let a user stop following a reader
+	router.HandleFunc("/followers", socialHandler.Followers).Methods("GET")                         // this lists the followers of a user (has query param user_id)
+	router.HandleFunc("/following", socialHandler.Following).Methods("GET")                         // this lists who a user follows (has query param user_id)
+	router.HandleFunc("/feed", socialHandler.Feed).Methods("GET")                                   // this is the activity timeline of the readers you follow (has query params page and limit)
+	// Featured content for the home screen
+	router.HandleFunc("/featured", socialHandler.Featured).Methods("GET")                           // this gives the quote of the day and the review of the week
+	router.HandleFunc("/featured/pin", socialHandler.PinFeatured).Methods("POST")                   // this will let the admin pin or unpin the featured quote or review
+	// Reporting offensive content
+	router.HandleFunc("/reports", socialHandler.ReportContent).Methods("POST")                      // this will let any user report a review, quote or comment with a reason
+	router.HandleFunc("/reports", socialHandler.ListReports).Methods("GET")                         // this is the admin report queue (has query param status)
+	router.HandleFunc("/resolve-report", socialHandler.ResolveReport).Methods("POST")               // this will let the admin resolve or dismiss the reports on a piece of content
+	// Book clubs
+	router.HandleFunc("/clubs", clubHandler.CreateClub).Methods("POST")                                // this will create a club with the caller as owner
+	router.HandleFunc("/clubs", clubHandler.ListClubs).Methods("GET")                                  // this lists all clubs with their current book
+	router.HandleFunc("/clubs/{id}", clubHandler.GetClub).Methods("GET")                               // this shows a club, its members, schedule and your progress against it
+	router.HandleFunc("/clubs/{id}/book", clubHandler.SetClubBook).Methods("POST")                     // this will let the owner or a moderator set the book and milestone schedule
+	router.HandleFunc("/clubs/{id}/moderators", clubHandler.SetModerator).Methods("POST")              // this will let the owner add or remove a moderator
+	router.HandleFunc("/clubs/{id}/invite", clubHandler.InviteToClub).Methods("POST")                  // this will let the owner or a moderator invite a reader by reader_id
+	router.HandleFunc("/clubs/{id}/join", clubHandler.JoinClub).Methods("POST")                        // this will send a join request, or accept a pending invitation
+	router.HandleFunc("/clubs/{id}/requests", clubHandler.ListClubRequests).Methods("GET")             // this lists the pending invitations and join requests of a club
+	router.HandleFunc("/clubs/{id}/leave", clubHandler.LeaveClub).Methods("POST")                      // this will let a member leave the club
+	router.HandleFunc("/club-invites/{id}/respond", clubHandler.RespondToInvite).Methods("POST")       // this will accept or decline an invitation or join request
+	router.HandleFunc("/clubs/{id}/milestones/{milestone}/posts", clubHandler.ListMilestonePosts).Methods("GET") // this shows a milestone discussion once you have read up to it
+	router.HandleFunc("/clubs/{id}/milestones/{milestone}/posts", clubHandler.AddMilestonePost).Methods("POST")  // this will add a post to a milestone discussion
+	// Rank score ledger
+	router.HandleFunc("/me/score-history", socialHandler.ScoreHistory).Methods("GET")              // this explains your rank score event by event (has query params page and limit)
+	router.HandleFunc("/recompute-scores", socialHandler.RecomputeScores).Methods("POST")          // this will let the admin rebuild every rank score from the ledger
+	// Badge catalog
+	router.HandleFunc("/badges/catalog", socialHandler.BadgeCatalog).Methods("GET")                 // this lists the badges that can be earned and their rules
+	router.HandleFunc("/badges/catalog", socialHandler.SaveBadgeDefinition).Methods("POST")         // this will let the admin add or change a badge and its rule
+	router.HandleFunc("/badges/catalog", socialHandler.RetireBadgeDefinition).Methods("DELETE")     // this will let the admin retire a badge (has query param name)
+	router.HandleFunc("/badges/progress", socialHandler.BadgeProgress).Methods("GET")               // this shows earned badges and progress towards the rest (has query param user_id)
+	router.HandleFunc("/award-badge", socialHandler.AwardBadge).Methods("POST")                     // this will let the admin award a custom badge with a reason
+	router.HandleFunc("/revoke-badge", socialHandler.RevokeBadge).Methods("POST")                   // this will let the admin revoke a badge, taking back its points
+	router.HandleFunc("/badge-audits", socialHandler.BadgeAudits).Methods("GET")                    // this is the admin log of awarded and revoked badges (has query param user_id)
+	router.HandleFunc("/class-tag-history", socialHandler.ClassTagHistory).Methods("GET")           // this shows the class tag changes of a reader and the tiers (has query param user_id)
+	// webhooks (admin only)
+	router.HandleFunc("/webhooks", socialHandler.CreateWebhook).Methods("POST")                               // this will register an endpoint for some event types (body url, events, secret)
+	router.HandleFunc("/webhooks", socialHandler.ListWebhooks).Methods("GET")                                 // this will list the webhooks and the event types they can use
+	router.HandleFunc("/webhooks/{id}", socialHandler.DeleteWebhook).Methods("DELETE")                        // this will remove a webhook
+	router.HandleFunc("/webhooks/{id}/deliveries", socialHandler.WebhookDeliveries).Methods("GET")            // this will show the delivery log of a webhook (query params status, page, limit)
+	router.HandleFunc("/webhook-deliveries/{id}/retry", socialHandler.RetryWebhookDelivery).Methods("POST") // this will send a dead delivery again
+
+	// scheduled jobs (admin only)
+	router.HandleFunc("/jobs", socialHandler.ListJobs).Methods("GET")               // this will list the jobs with their schedule, next and last run
+	router.HandleFunc("/jobs/{name}/run", socialHandler.RunJob).Methods("POST")     // this will start a job now
+	router.HandleFunc("/jobs/{name}/runs", socialHandler.JobRuns).Methods("GET")    // this will show the run history of a job (query params status, page, limit)
+
 	// Start the server
 	port := os.Getenv("PORT")
 	log.Printf("Server starting on :%s...", port)
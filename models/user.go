@@ -22,6 +22,7 @@ type User struct {
 	ClassTag          string             `bson:"class_tag"`
 	CreatedAt         time.Time          `bson:"created_at"`
 	MustChangePassword bool 			 `bson:"must_change_password"`
+	TokenVersion      int                `bson:"token_version"` // bumping it ends every session of the user
 }
 
 type PendingRegistration struct {
@@ -52,6 +53,10 @@ type Book struct {
 	CreatedAt               time.Time          `bson:"created_at"`
 	AboutTheBook            string             `bson:"about_the_book"`
 	TotalPages              int                `bson:"total_pages"`
+	RatingAverage           float64            `bson:"rating_average"`
+	RatingCount             int                `bson:"rating_count"`
+	RatingSum               float64            `bson:"rating_sum"`
+	RatingDistribution      map[string]int     `bson:"rating_distribution,omitempty"` // whole stars "1".."5", half stars round down
 }
 
 type BorrowHistory struct {
@@ -64,6 +69,8 @@ type BorrowHistory struct {
 	BorrowDate time.Time          `bson:"borrow_date"`
 	ReturnDate time.Time          `bson:"return_date,omitempty"`
 	Type       string             `bson:"type"` // "hardcopy" or "softcopy"
+	DueDate    time.Time          `bson:"due_date,omitempty"`
+	RemindersSent []string        `bson:"reminders_sent,omitempty"` // due_soon, due_today, overdue_<days>, escalated
 }
 
 type ReadingProgress struct {
@@ -89,6 +96,7 @@ type Review struct {
 	UserID        primitive.ObjectID   `bson:"user_id"`
 	ReaderID      string               `bson:"reader_id"`
 	ReviewText    string               `bson:"review_text"`
+	Rating        float64              `bson:"rating,omitempty"` // 1-5 in half-star steps, 0 if not rated
 	AICheckStatus string               `bson:"ai_check_status"` // "pending", "approved", "rejected"
 	AIScore       float64              `bson:"ai_score"`
 	Posted        bool                 `bson:"posted"`
@@ -96,6 +104,24 @@ type Review struct {
 	UpvotedBy     []primitive.ObjectID `bson:"upvoted_by"` // NEW
 	CreatedAt     time.Time            `bson:"created_at"`
 	BookDeleted   bool                  `bson:"book_deleted"`
+	MinHash        []uint32          `bson:"minhash,omitempty" json:"-"` // similarity signature of review_text
+	MinHashBands   []int64           `bson:"minhash_bands,omitempty" json:"-"` // LSH band keys of minhash, indexed
+	SimilarReviews []SimilarityMatch `bson:"similar_reviews,omitempty"`
+	Flagged        bool              `bson:"flagged"`                // near-exact copy found on submission
+	FlagReason     string            `bson:"flag_reason,omitempty"`
+	ApprovedBefore bool              `bson:"approved_before"` // books_read and score were already awarded once
+	Hidden         bool              `bson:"hidden"`          // hidden after too many reports
+	EditedAt       time.Time         `bson:"edited_at,omitempty"`
+}
+
+// SimilarityMatch is another review that looks like the one it is attached to
+type SimilarityMatch struct {
+	ReviewID   primitive.ObjectID `bson:"review_id" json:"review_id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	ReaderID   string             `bson:"reader_id" json:"reader_id"`
+	BookID     primitive.ObjectID `bson:"book_id" json:"book_id"`
+	Similarity float64            `bson:"similarity" json:"similarity"`
+	SameAuthor bool               `bson:"same_author" json:"same_author"`
 }
 
 
@@ -117,26 +143,37 @@ type Badge struct {
     Name        string             `bson:"name"`
     Description string             `bson:"description"`
     Type        string             `bson:"type"` // "achievement", "class-tag", etc.
+    Icon        string             `bson:"icon,omitempty"`
     CreatedAt   time.Time          `bson:"created_at"`
 }
 
 type Quote struct {
     ID        primitive.ObjectID   `bson:"_id,omitempty"`
     Text      string               `bson:"text"`
-    AuthorID  primitive.ObjectID   `bson:"author_id"`
+    UserID    primitive.ObjectID   `bson:"user_id"` // author, stored as user_id like every other quote query
+    BookID    primitive.ObjectID   `bson:"book_id,omitempty"`
+    ISBN      string               `bson:"isbn,omitempty"`
+    Page      int                  `bson:"page,omitempty"`
+    Chapter   string               `bson:"chapter,omitempty"`
     CreatedAt time.Time            `bson:"created_at"`
     Upvotes   int                  `bson:"upvotes"`
     UpvotedBy []primitive.ObjectID `bson:"upvoted_by"`
+    EditedAt  time.Time            `bson:"edited_at,omitempty"`
+    Hidden    bool                 `bson:"hidden"`
 }
 
 type QuoteComment struct {
     ID        primitive.ObjectID   `bson:"_id,omitempty"`
     QuoteID   primitive.ObjectID   `bson:"quote_id"`
+    ParentID  primitive.ObjectID   `bson:"parent_id,omitempty"` // set on replies
+    Depth     int                  `bson:"depth"`
     UserID    primitive.ObjectID   `bson:"user_id"`
     Text      string               `bson:"text"`
     CreatedAt time.Time            `bson:"created_at"`
     Upvotes   int                  `bson:"upvotes"`
     UpvotedBy []primitive.ObjectID `bson:"upvoted_by"`
+    EditedAt  time.Time            `bson:"edited_at,omitempty"`
+    Hidden    bool                 `bson:"hidden"`
 }
 
 
@@ -144,11 +181,15 @@ type QuoteComment struct {
 type ReviewComment struct {
 	ID        primitive.ObjectID   `bson:"_id,omitempty"`
 	ReviewID  primitive.ObjectID   `bson:"review_id"`   // The review being commented on
+	ParentID  primitive.ObjectID   `bson:"parent_id,omitempty"` // The comment being replied to, if any
+	Depth     int                  `bson:"depth"`       // 0 for top level comments
 	UserID    primitive.ObjectID   `bson:"user_id"`     // Author of the comment
 	Text      string               `bson:"text"`        // Comment text
 	Upvotes   int                  `bson:"upvotes"`     // Number of upvotes
 	UpvotedBy []primitive.ObjectID `bson:"upvoted_by"`  // Users who upvoted this comment
 	CreatedAt time.Time            `bson:"created_at"`  // Timestamp of creation
+	EditedAt  time.Time            `bson:"edited_at,omitempty"`
+	Hidden    bool                 `bson:"hidden"`
 }
 
 
@@ -159,5 +200,272 @@ type Notification struct {
 	Type      string             `bson:"type"`     // "upvote", "comment"
 	TargetID  primitive.ObjectID `bson:"target_id"`
 	Seen      bool               `bson:"seen"`
+	Summary   map[string]int     `bson:"summary,omitempty"` // digest only: how many of each type it covers
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// Revision keeps the previous text of a review, quote or comment each time it is edited
+type Revision struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	ContentType string             `bson:"content_type"` // "review", "review_comment", "quote", "quote_comment"
+	ContentID   primitive.ObjectID `bson:"content_id"`
+	Text        string             `bson:"text"`
+	Rating      float64            `bson:"rating,omitempty"` // reviews only
+	EditedBy    primitive.ObjectID `bson:"edited_by"`
+	EditedAt    time.Time          `bson:"edited_at"`
+}
+
+// Report is one user flagging a review, quote or comment
+type Report struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	ContentType string             `bson:"content_type"` // "review", "review_comment", "quote", "quote_comment"
+	ContentID   primitive.ObjectID `bson:"content_id"`
+	AuthorID    primitive.ObjectID `bson:"author_id"` // who wrote the reported content
+	ReporterID  primitive.ObjectID `bson:"reporter_id"`
+	Reason      string             `bson:"reason"`
+	Status      string             `bson:"status"` // "open", "resolved", "dismissed"
+	CreatedAt   time.Time          `bson:"created_at"`
+	ResolvedBy  primitive.ObjectID `bson:"resolved_by,omitempty"`
+	ResolvedAt  time.Time          `bson:"resolved_at,omitempty"`
+}
+
+// FeaturedPick is the quote of the day or review of the week chosen for one period
+type FeaturedPick struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Kind      string             `bson:"kind"`   // "quote" (daily) or "review" (weekly)
+	Period    string             `bson:"period"` // "2006-01-02" of the day, or of the Monday for reviews
+	ContentID primitive.ObjectID `bson:"content_id"`
+	Pinned    bool               `bson:"pinned"` // chosen by an admin instead of the selector
+	PinnedBy  primitive.ObjectID `bson:"pinned_by,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// Follow means FollowerID sees FolloweeID's activity in their feed
+type Follow struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	FollowerID primitive.ObjectID `bson:"follower_id"`
+	FolloweeID primitive.ObjectID `bson:"followee_id"`
+	CreatedAt  time.Time          `bson:"created_at"`
+}
+
+// Activity is one event shown in followers' feeds, recorded when it happens
+type Activity struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Type      string             `bson:"type"` // "started_reading", "progress_milestone", "review_approved", "new_quote", "badge_earned"
+	BookID    primitive.ObjectID `bson:"book_id,omitempty"`
+	TargetID  primitive.ObjectID `bson:"target_id,omitempty"` // review, quote or badge
+	Detail    string             `bson:"detail,omitempty"`    // e.g. "50%" or the badge name
+	CreatedAt time.Time          `bson:"created_at"`
+	Hidden    bool               `bson:"hidden,omitempty"` // the target is hidden after reports
+}
+
+// Club is a book club reading one book together on a schedule
+type Club struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty"`
+	Name        string               `bson:"name"`
+	Description string               `bson:"description"`
+	OwnerID     primitive.ObjectID   `bson:"owner_id"`
+	Moderators  []primitive.ObjectID `bson:"moderators"`
+	Members     []primitive.ObjectID `bson:"members"` // includes the owner and moderators
+	BookID      primitive.ObjectID   `bson:"book_id,omitempty"`
+	ISBN        string               `bson:"isbn,omitempty"`
+	Schedule    []ClubMilestone      `bson:"schedule"`
+	CreatedAt   time.Time            `bson:"created_at"`
+}
+
+// ClubMilestone is a point in the club book members should reach by a date
+type ClubMilestone struct {
+	ID      primitive.ObjectID `bson:"_id" json:"id"`
+	Title   string             `bson:"title" json:"title"`
+	Page    int                `bson:"page" json:"page"`
+	Chapter string             `bson:"chapter,omitempty" json:"chapter,omitempty"`
+	DueDate time.Time          `bson:"due_date" json:"due_date"`
+}
+
+// ClubInvite is either an invitation sent by a moderator or a join request sent by a reader
+type ClubInvite struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	ClubID    primitive.ObjectID `bson:"club_id"`
+	UserID    primitive.ObjectID `bson:"user_id"` // the reader who would join
+	InvitedBy primitive.ObjectID `bson:"invited_by,omitempty"`
+	Kind      string             `bson:"kind"`   // "invite" or "request"
+	Status    string             `bson:"status"` // "pending", "accepted", "declined"
 	CreatedAt time.Time          `bson:"created_at"`
 }
+
+// ClubPost is a message in a milestone's discussion thread
+type ClubPost struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	ClubID      primitive.ObjectID `bson:"club_id"`
+	MilestoneID primitive.ObjectID `bson:"milestone_id"`
+	UserID      primitive.ObjectID `bson:"user_id"`
+	Text        string             `bson:"text"`
+	CreatedAt   time.Time          `bson:"created_at"`
+}
+
+// ScoreEvent is one change to a user's rank score; rank_score is the sum of them
+type ScoreEvent struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventID    primitive.ObjectID `bson:"event_id,omitempty" json:"-"` // the domain event that caused it, if any
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Delta      int                `bson:"delta" json:"delta"`
+	Reason     string             `bson:"reason" json:"reason"`
+	SourceType string             `bson:"source_type,omitempty" json:"source_type,omitempty"` // "review", "quote", "review_comment", "badge"...
+	SourceID   primitive.ObjectID `bson:"source_id,omitempty" json:"source_id,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	Pending    bool               `bson:"pending,omitempty" json:"-"` // written but not yet added to rank_score
+}
+
+// BadgeDefinition is an achievement badge in the catalog, earned when every
+// condition of its rule holds
+type BadgeDefinition struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	Icon        string             `bson:"icon" json:"icon"`
+	Score       int                `bson:"score" json:"score"`
+	Rule        []BadgeCondition   `bson:"rule" json:"rule"`
+	Active      bool               `bson:"active" json:"active"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// BadgeCondition asks for one of the user's counters to reach a minimum
+type BadgeCondition struct {
+	Counter string `bson:"counter" json:"counter"` // see helpers.BadgeCounters
+	Min     int    `bson:"min" json:"min"`
+}
+
+// BadgeAudit records an admin awarding or revoking a badge
+type BadgeAudit struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Action     string             `bson:"action" json:"action"` // "award" or "revoke"
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	AdminID    primitive.ObjectID `bson:"admin_id" json:"admin_id"`
+	BadgeID    primitive.ObjectID `bson:"badge_id" json:"badge_id"`
+	BadgeName  string             `bson:"badge_name" json:"badge_name"`
+	Reason     string             `bson:"reason" json:"reason"`
+	ScoreDelta int                `bson:"score_delta" json:"score_delta"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// ClassTagChange is one entry in a reader's class tag history
+type ClassTagChange struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	From      string             `bson:"from" json:"from"`
+	To        string             `bson:"to" json:"to"`
+	Promoted  bool               `bson:"promoted" json:"promoted"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// NotificationPreferences says per notification type whether a user gets it right
+// away ("instant"), in the daily digest ("digest") or not at all ("off")
+type NotificationPreferences struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Modes     map[string]string  `bson:"modes"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+}
+
+// DigestItem is a notification held back for a user's next digest
+type DigestItem struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	ActorID   primitive.ObjectID `bson:"actor_id"`
+	Type      string             `bson:"type"`
+	TargetID  primitive.ObjectID `bson:"target_id"`
+	CreatedAt time.Time          `bson:"created_at"`
+	DigestID  primitive.ObjectID `bson:"digest_id,omitempty"` // set once a digest run has claimed it; the digest notification's _id
+}
+
+// OutboxEmail is an email waiting to be sent (or already sent); it is rendered
+// when queued so a restart only has to deliver it. Emails carrying a secret (a
+// password reset) are rendered when sent instead, from Data and ResetID
+type OutboxEmail struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	To            string             `bson:"to" json:"to"`
+	Template      string             `bson:"template" json:"template"`
+	Subject       string             `bson:"subject" json:"subject"`
+	Text          string             `bson:"text" json:"-"`
+	HTML          string             `bson:"html" json:"-"`
+	Status        string             `bson:"status" json:"status"` // pending, sent or failed
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	SentAt        *time.Time         `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+	Data          map[string]string  `bson:"data,omitempty" json:"-"`
+	ResetID       primitive.ObjectID `bson:"reset_id,omitempty" json:"-"`
+	ExpireAt      *time.Time         `bson:"expire_at,omitempty" json:"-"` // TTL, set once sent or failed
+}
+
+// Webhook is an endpoint an admin registered to be told about some event types
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"-"`
+	Events    []string           `bson:"events" json:"events"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedBy primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// WebhookDelivery is one event on its way to one webhook; deliveries that keep
+// failing stay in the collection with status "dead" as the dead-letter log
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WebhookID      primitive.ObjectID `bson:"webhook_id" json:"webhook_id"`
+	EventID        primitive.ObjectID `bson:"event_id" json:"event_id"`
+	EventType      string             `bson:"event_type" json:"event_type"`
+	Payload        string             `bson:"payload" json:"payload"`
+	Status         string             `bson:"status" json:"status"` // pending, delivered or dead
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastStatusCode int                `bson:"last_status_code,omitempty" json:"last_status_code,omitempty"`
+	LastError      string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time         `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+}
+
+// JobRun is one run of a scheduled job
+type JobRun struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Job        string             `bson:"job" json:"job"`
+	Trigger    string             `bson:"trigger" json:"trigger"` // schedule or manual
+	Instance   string             `bson:"instance" json:"instance"`
+	Status     string             `bson:"status" json:"status"` // running, success or failed
+	Result     string             `bson:"result,omitempty" json:"result,omitempty"`
+	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt  time.Time          `bson:"started_at" json:"started_at"`
+	FinishedAt *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	DurationMS int64              `bson:"duration_ms" json:"duration_ms"`
+}
+
+// PasswordResetToken is a one-time password reset; only the sha256 of the token is stored
+type PasswordResetToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	TokenHash string             `bson:"token_hash"`
+	Reason    string             `bson:"reason"` // forgot or admin
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// Session is a logged in device. It holds the hash of its current refresh token and
+// of the one before, so reuse of a rotated token can be spotted
+type Session struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"-"`
+	RefreshHash  string             `bson:"refresh_hash" json:"-"`
+	PreviousHash string             `bson:"previous_hash,omitempty" json:"-"`
+	TokenVersion int                `bson:"token_version" json:"-"`
+	Device       string             `bson:"device,omitempty" json:"device,omitempty"`
+	UserAgent    string             `bson:"user_agent" json:"user_agent"`
+	IP           string             `bson:"ip" json:"ip"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt   time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt    time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt    *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}